@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+const (
+	// EnvArtifactsDir is the environment variable that sets the directory
+	// the actual response of failed tests is written to if
+	// [WithArtifactsOnFailure] is not used.
+	EnvArtifactsDir = "FNTEST_ARTIFACTS_DIR"
+
+	// Names of the files the actual response is written to.
+	artifactComposite = "expect_composite.yaml"
+	artifactComposed  = "expect_composed.yaml"
+	artifactResults   = "results.yaml"
+	artifactContext   = "context.yaml"
+)
+
+// WithArtifactsOnFailure writes the actual response of the function to a
+// directory per test below dir if the test fails:
+//
+//   - expect_composite.yaml contains the desired composite.
+//   - expect_composed.yaml contains the desired composed resources, annotated
+//     with [AnnotationKeyResourceName].
+//   - results.yaml contains the results.
+//   - context.yaml contains the context.
+//
+// The files can be copied over the fixtures of [ExpectDesiredCompositeYAML]
+// and [ExpectDesiredResourcesYAML]. Without this option, the directory is
+// read from the environment variable [EnvArtifactsDir], and no files are
+// written if it is not set either.
+func WithArtifactsOnFailure(dir string) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.artifactsDir = dir
+	}
+}
+
+// writeArtifactsOnFailure writes the artifacts of res when the test finished
+// if it failed. Artifacts of earlier calls in the same test are overwritten.
+func (tc *FunctionTest) writeArtifactsOnFailure(t testing.TB, res *fnapi.RunFunctionResponse) {
+	dir := tc.artifactsDir
+	if dir == "" {
+		dir = os.Getenv(EnvArtifactsDir)
+	}
+	if dir == "" {
+		return
+	}
+	dir = filepath.Join(dir, filepath.FromSlash(t.Name()))
+
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		if err := writeArtifacts(dir, res); err != nil {
+			t.Errorf("Cannot write actual response: %s", err)
+			return
+		}
+		t.Logf("Actual response written to %s", dir)
+	})
+}
+
+// writeArtifacts writes the desired state, results and context of res as
+// YAML files to dir.
+func writeArtifacts(dir string, res *fnapi.RunFunctionResponse) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrap(err, "cannot create directory")
+	}
+
+	files := map[string]func() ([]byte, error){
+		artifactComposite: func() ([]byte, error) {
+			u := convertResourceToUnstructured(res.GetDesired().GetComposite())
+			if u == nil {
+				return nil, nil
+			}
+			return yaml.Marshal(u.Object)
+		},
+		artifactComposed: func() ([]byte, error) {
+			resources := convertResourcesMapToUnstructured(res.GetDesired().GetResources())
+			objects := make([]map[string]any, 0, len(resources))
+			for _, name := range maps.SortedKeys(resources) {
+				u := resources[name]
+				meta.AddAnnotations(u, map[string]string{AnnotationKeyResourceName: name})
+				objects = append(objects, u.Object)
+			}
+			return yaml.MarshalObjects(objects)
+		},
+		artifactResults: func() ([]byte, error) {
+			results := make([]any, 0, len(res.GetResults()))
+			for _, r := range res.GetResults() {
+				v, err := protoAsJSONValue(r)
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, v)
+			}
+			return yaml.Marshal(results)
+		},
+		artifactContext: func() ([]byte, error) {
+			if res.GetContext() == nil {
+				return yaml.Marshal(map[string]any{})
+			}
+			return yaml.Marshal(res.GetContext().AsMap())
+		},
+	}
+
+	for _, name := range maps.SortedKeys(files) {
+		raw, err := files[name]()
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal %s", name)
+		}
+		path := filepath.Join(dir, name)
+		if raw == nil {
+			// Remove stale files of earlier runs.
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "cannot remove %s", path)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, raw, 0o644); err != nil { //nolint:gosec // Artifacts are meant to be read.
+			return errors.Wrapf(err, "cannot write %s", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/response"
+	"google.golang.org/protobuf/encoding/protojson"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+// composingFunction returns a function that sets every part of the response
+// that is written as artifact.
+func composingFunction(t *testing.T) *testFunction {
+	t.Helper()
+	return &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		res := response.To(req, response.DefaultTTL)
+		res.Desired.Composite = &fnapi.Resource{Resource: mustObjectAsStruct(&unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "example.org/v1",
+			"kind":       "XBucket",
+			"status":     map[string]any{"ready": true},
+		}})}
+		res.Desired.Resources = map[string]*fnapi.Resource{
+			"bucket": {Resource: mustObjectAsStruct(&unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       "Bucket",
+				"spec":       map[string]any{"region": "eu"},
+			}})},
+			"policy": {Resource: mustObjectAsStruct(&unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       "BucketPolicy",
+				"metadata":   map[string]any{"labels": map[string]any{"team": "a"}},
+			}})},
+		}
+		response.SetContextKey(res, "example.org/region", mustStructValue("eu"))
+		response.Normal(res, "composed bucket")
+		return res, nil
+	}}
+}
+
+// readArtifacts returns the expectations of the artifacts in dir.
+func readArtifacts(t *testing.T, dir string) []TestFunctionOpt {
+	t.Helper()
+	read := func(name string) []byte {
+		t.Helper()
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	var results []any
+	if err := yaml.Unmarshal(read(artifactResults), &results); err != nil {
+		t.Fatal(err)
+	}
+	expectedResults := make([]*fnapi.Result, len(results))
+	for i, r := range results {
+		raw, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedResults[i] = &fnapi.Result{}
+		if err := protojson.Unmarshal(raw, expectedResults[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ctx map[string]any
+	if err := yaml.Unmarshal(read(artifactContext), &ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []TestFunctionOpt{
+		ExpectDesiredCompositeYAML(read(artifactComposite)),
+		ExpectDesiredResourcesYAML(read(artifactComposed)),
+		ExpectResults(expectedResults),
+	}
+	for k, v := range ctx {
+		opts = append(opts, ExpectContextValue(k, v))
+	}
+	return opts
+}
+
+func TestArtifactsAsFixtures(t *testing.T) {
+	fn := composingFunction(t)
+	cases := map[string]struct {
+		// opts configure the artifacts directory to dir.
+		opts func(t *testing.T, dir string) []TestFunctionOpt
+	}{
+		"Option": {
+			opts: func(_ *testing.T, dir string) []TestFunctionOpt {
+				return []TestFunctionOpt{WithArtifactsOnFailure(dir)}
+			},
+		},
+		"Environment": {
+			opts: func(t *testing.T, dir string) []TestFunctionOpt {
+				t.Setenv(EnvArtifactsDir, dir)
+				return nil
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			opts := c.opts(t, dir)
+
+			// The test fails, because it expects no desired resources. The
+			// artifacts are written when the subtest finished.
+			var failedName string
+			t.Run("Failed", func(t *testing.T) {
+				failedName = t.Name()
+				ft := &fakeTB{TB: t}
+				ft.run(func() { runFunctionTest(ft, fn, opts...) })
+				if !ft.Failed() || ft.fatal {
+					t.Fatalf("want the test without expectations to fail, got %q", ft.errors)
+				}
+			})
+
+			artifacts := readArtifacts(t, filepath.Join(dir, filepath.FromSlash(failedName)))
+			if got := failedAssertions(EvaluateFunction(t.Name(), fn, artifacts...)); len(got) > 0 {
+				t.Errorf("want the artifacts to match the response, got failed assertions %q", got)
+			}
+		})
+	}
+}
+
+func TestArtifactsNotWrittenOnSuccess(t *testing.T) {
+	dir := t.TempDir()
+	t.Run("Passed", func(t *testing.T) {
+		runFunctionTest(t, desiredResourcesFunction(t, nil), WithArtifactsOnFailure(dir))
+	})
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+		t.Errorf("want no artifacts, got %v (error: %v)", entries, err)
+	}
+}
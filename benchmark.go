@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// BenchmarkFunction builds the request once from opts and runs fn b.N times
+// with it. Allocations are reported.
+//
+// Every run receives a fresh copy of the request. The copies are created
+// before the timer starts, so they are held in memory at the same time.
+// Expectations configured by opts are ignored.
+func BenchmarkFunction(b *testing.B, fn fnapi.FunctionRunnerServiceServer, opts ...TestFunctionOpt) {
+	b.Helper()
+	tc := generateTc(fn)
+
+	// Apply user options
+	for _, o := range opts {
+		o(tc)
+	}
+	if err := tc.setup(); err != nil {
+		b.Fatal(errors.Wrapf(err, "cannot set up benchmark"))
+	}
+
+	// Stopping the timer for every run would distort the measurement of fast
+	// functions, so all copies are created upfront.
+	reqs := make([]*fnapi.RunFunctionRequest, b.N)
+	for i := range reqs {
+		reqs[i] = proto.Clone(tc.req).(*fnapi.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i, req := range reqs {
+		if _, err := tc.runFunction(req); err != nil {
+			b.Fatal(errors.Wrapf(err, "run %d returned an error", i+1))
+		}
+	}
+}
+
+// BenchmarkFunctionScaled runs [BenchmarkFunction] as a sub-benchmark for
+// every count in counts. Each sub-benchmark adds count copies of the object
+// in templateYAML to the observed resources, which shows how the function
+// scales with the size of a composition. The copies are generated by
+// [SyntheticResources].
+func BenchmarkFunctionScaled(b *testing.B, fn fnapi.FunctionRunnerServiceServer, templateYAML []byte, counts []int, opts ...TestFunctionOpt) {
+	b.Helper()
+	for _, n := range counts {
+		b.Run(fmt.Sprintf("resources=%d", n), func(b *testing.B) {
+			BenchmarkFunction(b, fn, append(opts[:len(opts):len(opts)], WithSyntheticObservedResources(templateYAML, n))...)
+		})
+	}
+}
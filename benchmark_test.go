@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"flag"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/response"
+	"github.com/pkg/errors"
+)
+
+// setBenchtime sets the flag -test.benchtime for the test, which is used by
+// testing.Benchmark.
+func setBenchtime(t *testing.T, benchtime string) {
+	t.Helper()
+	f := flag.Lookup("test.benchtime")
+	previous := f.Value.String()
+	if err := f.Value.Set(benchtime); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Value.Set(previous) })
+}
+
+func TestBenchmarkFunction(t *testing.T) {
+	setBenchtime(t, "100x")
+	runs := 0
+	mutated := 0
+	// mutating counts the runs that received a request mutated by an earlier
+	// run.
+	mutating := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		runs++
+		fields := req.GetObserved().GetComposite().GetResource().GetFields()
+		if fields["kind"].GetStringValue() != "XBucket" {
+			mutated++
+		}
+		fields["kind"] = mustStructValue("Mutated")
+		return response.To(req, response.DefaultTTL), nil
+	}}
+
+	r := testing.Benchmark(func(b *testing.B) {
+		BenchmarkFunction(b, mutating, WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}}`)))
+	})
+	if r.N == 0 {
+		t.Fatal("benchmark failed")
+	}
+	// testing.Benchmark runs the benchmark with b.N = 1 before the requested
+	// iterations.
+	if r.N != 100 || runs != 101 {
+		t.Errorf("want 100 iterations and 101 runs, got %d and %d", r.N, runs)
+	}
+	if mutated > 0 {
+		t.Errorf("%d runs received a request mutated by an earlier run", mutated)
+	}
+}
+
+func TestBenchmarkFunctionError(t *testing.T) {
+	failing := &testFunction{run: func(*fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		return nil, errors.New("boom")
+	}}
+	if r := testing.Benchmark(func(b *testing.B) { BenchmarkFunction(b, failing) }); r.N != 0 {
+		t.Errorf("want the benchmark to fail, got %d runs", r.N)
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os/exec"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// startupPollInterval is the interval in which the port of a started function
+// is polled.
+const startupPollInterval = 100 * time.Millisecond
+
+// remoteFunction runs a function that is served via gRPC.
+type remoteFunction struct {
+	fnapi.UnimplementedFunctionRunnerServiceServer
+
+	client fnapi.FunctionRunnerServiceClient
+}
+
+func (f *remoteFunction) RunFunction(ctx context.Context, req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+	return f.client.RunFunction(ctx, req)
+}
+
+// connectFunction connects to the function at address without TLS.
+func connectFunction(address string) (*remoteFunction, io.Closer, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot connect to function at %s", address)
+	}
+	return &remoteFunction{client: fnapi.NewFunctionRunnerServiceClient(conn)}, conn, nil
+}
+
+// startFunction starts the function binary with args on a free local port
+// and waits until it accepts connections. Functions built with the function
+// SDK serve without TLS on the given address with the flags --insecure and
+// --address. The returned function kills the process.
+func startFunction(binary string, args []string, output io.Writer, timeout time.Duration) (string, func(), error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "cannot find a free port")
+	}
+	address := l.Addr().String()
+	if err := l.Close(); err != nil {
+		return "", nil, errors.Wrap(err, "cannot find a free port")
+	}
+
+	cmd := exec.Command(binary, append(args, "--insecure", "--address="+address)...) //nolint:gosec // Running the function under test is the purpose.
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		return "", nil, errors.Wrapf(err, "cannot start function %s", binary)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	stop := func() {
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, startupPollInterval)
+		if err == nil {
+			_ = conn.Close()
+			return address, stop, nil
+		}
+		select {
+		case err := <-exited:
+			return "", nil, errors.Errorf("function %s exited before it served on %s: %v", binary, address, err)
+		case <-time.After(startupPollInterval):
+		}
+		if time.Now().After(deadline) {
+			stop()
+			return "", nil, errors.Errorf("function %s did not serve on %s within %s", binary, address, timeout)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Command xfn-test runs declarative tests of a composition function without
+// writing Go code. Test cases are loaded from a suite YAML file or from a
+// directory with a subdirectory per test case and are evaluated with the same
+// comparison as TestFunction of the test framework.
+//
+// Usage:
+//
+//	xfn-test (-function-binary PATH | -function-address HOST:PORT) [flags] CASES
+//
+// The exit code is 0 if all test cases passed, 1 if any failed and 2 if the
+// test cases or the function could not be loaded.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	fntesting "github.com/dsd-dbs/crossplane-function-test-framework"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/diff"
+)
+
+const (
+	exitPassed = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+type options struct {
+	binary         string
+	binaryArgs     []string
+	address        string
+	startupTimeout time.Duration
+	timeout        time.Duration
+	crds           string
+	junit          string
+	json           string
+	verbose        bool
+	cases          string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	o, err := parseOptions(args, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	code, err := runCases(o, stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return code
+}
+
+func parseOptions(args []string, stderr io.Writer) (*options, error) {
+	o := &options{}
+	fs := flag.NewFlagSet("xfn-test", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: xfn-test (-function-binary PATH | -function-address HOST:PORT) [flags] CASES")
+		fmt.Fprintln(stderr, "\nCASES is a suite YAML file or a directory with a subdirectory per test case.")
+		fmt.Fprintln(stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&o.binary, "function-binary", "", "Function binary that is started for the tests.")
+	fs.Func("function-arg", "Additional argument of the function binary. Can be repeated.", func(s string) error {
+		o.binaryArgs = append(o.binaryArgs, s)
+		return nil
+	})
+	fs.StringVar(&o.address, "function-address", "", "Address of a running function that serves without TLS.")
+	fs.DurationVar(&o.startupTimeout, "startup-timeout", 30*time.Second, "Time the function binary is given to start serving.")
+	fs.DurationVar(&o.timeout, "timeout", time.Minute, "Timeout of each function run.")
+	fs.StringVar(&o.crds, "crds", "", "Directory of CRDs and XRDs to validate the desired state against.")
+	fs.StringVar(&o.junit, "junit", "", "Path of a JUnit XML report to write.")
+	fs.StringVar(&o.json, "json", "", "Path of a JSON report to write.")
+	fs.BoolVar(&o.verbose, "v", false, "Print passed assertions and the output of the function binary.")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, errors.New("expected exactly one path of test cases")
+	}
+	o.cases = fs.Arg(0)
+	if (o.binary == "") == (o.address == "") {
+		return nil, errors.New("expected either -function-binary or -function-address")
+	}
+	return o, nil
+}
+
+// runCases runs all test cases and returns the exit code.
+func runCases(o *options, stdout, stderr io.Writer) (int, error) {
+	cases, err := loadCases(o.cases)
+	if err != nil {
+		return exitError, err
+	}
+	common, err := commonOptions(o)
+	if err != nil {
+		return exitError, err
+	}
+
+	address := o.address
+	if o.binary != "" {
+		output := io.Discard
+		if o.verbose {
+			output = stderr
+		}
+		var stop func()
+		address, stop, err = startFunction(o.binary, o.binaryArgs, output, o.startupTimeout)
+		if err != nil {
+			return exitError, err
+		}
+		defer stop()
+	}
+	fn, conn, err := connectFunction(address)
+	if err != nil {
+		return exitError, err
+	}
+	defer conn.Close() //nolint:errcheck // Nothing to do on errors.
+
+	fntesting.EnableReport()
+	color := diff.UseColor(stdout, os.Getenv(fntesting.EnvColor))
+	failed := 0
+	for _, c := range cases {
+		start := time.Now()
+		assertions := fntesting.EvaluateFunction(c.name, fn, slices.Concat(common, c.opts)...)
+		if printCase(stdout, c.name, assertions, time.Since(start), o.verbose, color) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(stdout, "FAIL: %d of %d test cases failed\n", failed, len(cases))
+	} else {
+		fmt.Fprintf(stdout, "PASS: %d test cases passed\n", len(cases))
+	}
+
+	if o.junit != "" {
+		if err := fntesting.WriteJUnitReport(o.junit); err != nil {
+			return exitError, err
+		}
+	}
+	if o.json != "" {
+		if err := fntesting.WriteJSONReport(o.json); err != nil {
+			return exitError, err
+		}
+	}
+	if failed > 0 {
+		return exitFailed, nil
+	}
+	return exitPassed, nil
+}
+
+// commonOptions returns the options that apply to all test cases.
+func commonOptions(o *options) (opts []fntesting.TestFunctionOpt, err error) {
+	// Options panic on invalid fixtures.
+	defer func() {
+		if v := recover(); v != nil {
+			opts, err = nil, errors.Errorf("%v", v)
+		}
+	}()
+
+	opts = []fntesting.TestFunctionOpt{
+		fntesting.WithYAMLDiffs(),
+		fntesting.WithTimeout(o.timeout),
+	}
+	if o.crds != "" {
+		opts = append(opts, fntesting.WithCRDsFromDir(o.crds))
+	}
+	return opts, nil
+}
+
+// printCase prints the result of a test case in the format of go test and
+// returns true if it failed. Diffs are colored if color is true.
+func printCase(w io.Writer, name string, assertions []fntesting.Assertion, d time.Duration, verbose, color bool) bool {
+	failed := false
+	var lines []string
+	for _, a := range assertions {
+		if color {
+			a.Diff = diff.Colorize(a.Diff)
+		}
+		switch a.Status {
+		case fntesting.AssertionFailed:
+			failed = true
+			lines = append(lines, a.String())
+		case fntesting.AssertionWarning:
+			lines = append(lines, "Warning: "+a.String())
+		case fntesting.AssertionPassed:
+			if verbose {
+				lines = append(lines, "ok: "+a.Name)
+			}
+		}
+	}
+
+	status := "PASS"
+	if failed {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "--- %s: %s (%.2fs)\n", status, name, d.Seconds())
+	for _, l := range lines {
+		fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(strings.TrimRight(l, "\n"), "\n", "\n    "))
+	}
+	return failed
+}
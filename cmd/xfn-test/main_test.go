@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	fntesting "github.com/dsd-dbs/crossplane-function-test-framework"
+)
+
+func TestPrintCase(t *testing.T) {
+	assertions := []fntesting.Assertion{
+		{Name: "res.Desired.Composite", Status: fntesting.AssertionPassed},
+		{Name: `res.Desired.Resources["bucket"]`, Status: fntesting.AssertionFailed, Message: "-want +got", Diff: "@@ -1,1 +1,1 @@\n-a\n+b\n"},
+		{Name: "res.Desired.Resources", Status: fntesting.AssertionWarning, Message: "unexpected: extra"},
+	}
+	cases := map[string]struct {
+		verbose, color bool
+		want           string
+	}{
+		"Plain": {
+			want: "--- FAIL: case (1.00s)\n" +
+				"    res.Desired.Resources[\"bucket\"]: -want +got\n    @@ -1,1 +1,1 @@\n    -a\n    +b\n" +
+				"    Warning: res.Desired.Resources: unexpected: extra\n",
+		},
+		"Verbose": {
+			verbose: true,
+			want: "--- FAIL: case (1.00s)\n" +
+				"    ok: res.Desired.Composite\n" +
+				"    res.Desired.Resources[\"bucket\"]: -want +got\n    @@ -1,1 +1,1 @@\n    -a\n    +b\n" +
+				"    Warning: res.Desired.Resources: unexpected: extra\n",
+		},
+		"Color": {
+			color: true,
+			want: "--- FAIL: case (1.00s)\n" +
+				"    res.Desired.Resources[\"bucket\"]: -want +got\n    \x1b[36m@@ -1,1 +1,1 @@\x1b[0m\n    \x1b[31m-a\x1b[0m\n    \x1b[32m+b\x1b[0m\n" +
+				"    Warning: res.Desired.Resources: unexpected: extra\n",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := &strings.Builder{}
+			if failed := printCase(b, "case", assertions, time.Second, c.verbose, c.color); !failed {
+				t.Error("printCase(...): want failed")
+			}
+			if diff := cmp.Diff(c.want, b.String()); diff != "" {
+				t.Errorf("printCase(...): -want +got\n%s", diff)
+			}
+		})
+	}
+	if strings.Contains(assertions[1].Diff, "\x1b") {
+		t.Error("printCase(...) colored the diff of the assertion")
+	}
+}
@@ -0,0 +1,293 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	fntesting "github.com/dsd-dbs/crossplane-function-test-framework"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+// Files of a test case in the directory layout. The expectations use the same
+// names as the artifacts of failed tests, so they can be copied over.
+const (
+	fileObservedComposite = "arg_composite.yaml"
+	fileObservedComposed  = "arg_observed_composed.yaml"
+	fileInput             = "arg_input.yaml"
+	fileContext           = "arg_context.yaml"
+	fileEnvironment       = "arg_environment.yaml"
+	fileExpectComposite   = "expect_composite.yaml"
+	fileExpectComposed    = "expect_composed.yaml"
+	fileExpectResults     = "results.yaml"
+	fileExpectContext     = "context.yaml"
+	fileExpectError       = "expect_error.txt"
+)
+
+var caseFiles = []string{
+	fileObservedComposite, fileObservedComposed, fileInput, fileContext, fileEnvironment,
+	fileExpectComposite, fileExpectComposed, fileExpectResults, fileExpectContext, fileExpectError,
+}
+
+// testCase is a named set of options for a function test.
+type testCase struct {
+	name string
+	opts []fntesting.TestFunctionOpt
+}
+
+// loadCases loads the test cases from path, which is either a suite YAML file
+// or a directory with a subdirectory per test case.
+func loadCases(path string) ([]testCase, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read test cases")
+	}
+	if fi.IsDir() {
+		return loadCaseDirs(path)
+	}
+	return loadSuite(path)
+}
+
+// loadCaseDirs loads every directory below root that contains at least one of
+// the case files as test case named after its path relative to root.
+func loadCaseDirs(root string) ([]testCase, error) {
+	var cases []testCase
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		tc, err := loadCaseDir(filepath.ToSlash(name), path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot load test case %s", name)
+		}
+		if tc != nil {
+			cases = append(cases, *tc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, errors.Errorf("no test cases found in %s, expected directories with any of %s", root, strings.Join(caseFiles, ", "))
+	}
+	return cases, nil
+}
+
+// loadCaseDir loads the test case of dir. It returns nil if dir contains no
+// case files.
+func loadCaseDir(name, dir string) (tc *testCase, err error) {
+	// Options panic on invalid fixtures.
+	defer func() {
+		if v := recover(); v != nil {
+			tc, err = nil, errors.Errorf("%v", v)
+		}
+	}()
+
+	files := map[string][]byte{}
+	for _, f := range caseFiles {
+		raw, err := os.ReadFile(filepath.Join(dir, f)) //nolint:gosec // Reading test fixtures.
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[f] = raw
+	}
+	if len(files) == 0 {
+		return nil, nil //nolint:nilnil // Not a test case.
+	}
+
+	tc = &testCase{name: name}
+	if raw, ok := files[fileObservedComposite]; ok {
+		tc.opts = append(tc.opts, fntesting.WithObservedCompositeYAML(raw))
+	}
+	if raw, ok := files[fileObservedComposed]; ok {
+		tc.opts = append(tc.opts, fntesting.WithObservedResourcesYAML(raw))
+	}
+	if raw, ok := files[fileInput]; ok {
+		tc.opts = append(tc.opts, fntesting.WithInputYAML(raw))
+	}
+	if raw, ok := files[fileEnvironment]; ok {
+		tc.opts = append(tc.opts, fntesting.WithEnvironmentFromConfigsYAML(raw))
+	}
+	if raw, ok := files[fileContext]; ok {
+		ctx := map[string]any{}
+		if err := yaml.Unmarshal(raw, &ctx); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal %s", fileContext)
+		}
+		for _, k := range maps.SortedKeys(ctx) {
+			tc.opts = append(tc.opts, fntesting.WithContextValue(k, ctx[k]))
+		}
+	}
+	if raw, ok := files[fileExpectComposite]; ok {
+		tc.opts = append(tc.opts, fntesting.ExpectDesiredCompositeYAML(raw))
+	}
+	if raw, ok := files[fileExpectComposed]; ok {
+		tc.opts = append(tc.opts, fntesting.ExpectDesiredResourcesYAML(raw))
+	}
+	if raw, ok := files[fileExpectResults]; ok {
+		var results []any
+		if err := yaml.Unmarshal(raw, &results); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal %s", fileExpectResults)
+		}
+		opt, err := expectResults(results)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", fileExpectResults)
+		}
+		tc.opts = append(tc.opts, opt)
+	}
+	if raw, ok := files[fileExpectContext]; ok {
+		ctx := map[string]any{}
+		if err := yaml.Unmarshal(raw, &ctx); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal %s", fileExpectContext)
+		}
+		for _, k := range maps.SortedKeys(ctx) {
+			tc.opts = append(tc.opts, fntesting.ExpectContextValue(k, ctx[k]))
+		}
+	}
+	if raw, ok := files[fileExpectError]; ok {
+		tc.opts = append(tc.opts, fntesting.ExpectErrorContains(strings.TrimSpace(string(raw))))
+	}
+	return tc, nil
+}
+
+// suite is the format of a suite YAML file.
+type suite struct {
+	Cases []suiteCase `json:"cases"`
+}
+
+type suiteCase struct {
+	Name     string         `json:"name"`
+	Input    map[string]any `json:"input,omitempty"`
+	Context  map[string]any `json:"context,omitempty"`
+	Observed struct {
+		Composite map[string]any            `json:"composite,omitempty"`
+		Resources map[string]map[string]any `json:"resources,omitempty"`
+	} `json:"observed,omitempty"`
+	Expect struct {
+		Composite map[string]any            `json:"composite,omitempty"`
+		Resources map[string]map[string]any `json:"resources,omitempty"`
+		// AllowExtraResources reports unexpected resources as warning.
+		AllowExtraResources bool           `json:"allowExtraResources,omitempty"`
+		Results             []any          `json:"results,omitempty"`
+		Context             map[string]any `json:"context,omitempty"`
+		// Error is a substring of the expected error.
+		Error string `json:"error,omitempty"`
+	} `json:"expect,omitempty"`
+}
+
+// loadSuite loads the test cases of a suite YAML file.
+func loadSuite(path string) ([]testCase, error) {
+	raw, err := os.ReadFile(path) //nolint:gosec // Reading test fixtures.
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read suite")
+	}
+	s := &suite{}
+	// Unknown fields are most likely typos, which would silently test
+	// something else than intended.
+	if err := yaml.UnmarshalStrict(raw, s); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal suite")
+	}
+	if len(s.Cases) == 0 {
+		return nil, errors.Errorf("suite %s contains no test cases", path)
+	}
+
+	cases := make([]testCase, 0, len(s.Cases))
+	names := map[string]bool{}
+	for i, sc := range s.Cases {
+		if sc.Name == "" {
+			return nil, errors.Errorf("test case %d has no name", i)
+		}
+		if names[sc.Name] {
+			return nil, errors.Errorf("duplicate test case %q", sc.Name)
+		}
+		names[sc.Name] = true
+		tc, err := sc.testCase()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid test case %q", sc.Name)
+		}
+		cases = append(cases, tc)
+	}
+	return cases, nil
+}
+
+func (sc suiteCase) testCase() (tc testCase, err error) {
+	// Options panic on invalid objects.
+	defer func() {
+		if v := recover(); v != nil {
+			tc, err = testCase{}, errors.Errorf("%v", v)
+		}
+	}()
+
+	tc = testCase{name: sc.Name}
+	if sc.Input != nil {
+		tc.opts = append(tc.opts, fntesting.WithInput(&unstructured.Unstructured{Object: sc.Input}))
+	}
+	for _, k := range maps.SortedKeys(sc.Context) {
+		tc.opts = append(tc.opts, fntesting.WithContextValue(k, sc.Context[k]))
+	}
+	if sc.Observed.Composite != nil {
+		tc.opts = append(tc.opts, fntesting.WithObservedCompositeObject(&unstructured.Unstructured{Object: sc.Observed.Composite}))
+	}
+	for _, name := range maps.SortedKeys(sc.Observed.Resources) {
+		tc.opts = append(tc.opts, fntesting.WithObservedResourceObject(name, &unstructured.Unstructured{Object: sc.Observed.Resources[name]}))
+	}
+
+	if sc.Expect.Composite != nil {
+		tc.opts = append(tc.opts, fntesting.ExpectDesiredCompositeObject(&unstructured.Unstructured{Object: sc.Expect.Composite}))
+	}
+	for _, name := range maps.SortedKeys(sc.Expect.Resources) {
+		tc.opts = append(tc.opts, fntesting.ExpectDesiredResourceObject(name, &unstructured.Unstructured{Object: sc.Expect.Resources[name]}))
+	}
+	if sc.Expect.AllowExtraResources {
+		tc.opts = append(tc.opts, fntesting.AllowExtraDesiredResources())
+	}
+	if sc.Expect.Results != nil {
+		opt, err := expectResults(sc.Expect.Results)
+		if err != nil {
+			return testCase{}, errors.Wrap(err, "invalid results")
+		}
+		tc.opts = append(tc.opts, opt)
+	}
+	for _, k := range maps.SortedKeys(sc.Expect.Context) {
+		tc.opts = append(tc.opts, fntesting.ExpectContextValue(k, sc.Expect.Context[k]))
+	}
+	if sc.Expect.Error != "" {
+		tc.opts = append(tc.opts, fntesting.ExpectErrorContains(sc.Expect.Error))
+	}
+	return tc, nil
+}
+
+// expectResults parses results in the JSON representation of
+// [fnapi.Result], e.g. {"severity": "SEVERITY_NORMAL", "message": "..."}.
+func expectResults(values []any) (fntesting.TestFunctionOpt, error) {
+	results := make([]*fnapi.Result, len(values))
+	for i, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot marshal result %d", i)
+		}
+		results[i] = &fnapi.Result{}
+		if err := protojson.Unmarshal(raw, results[i]); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal result %d", i)
+		}
+	}
+	return fntesting.ExpectResults(results), nil
+}
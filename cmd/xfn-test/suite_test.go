@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/response"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	fntesting "github.com/dsd-dbs/crossplane-function-test-framework"
+)
+
+// bucketFunction composes a bucket in the region of the observed composite.
+type bucketFunction struct {
+	fnapi.UnimplementedFunctionRunnerServiceServer
+}
+
+func (f *bucketFunction) RunFunction(_ context.Context, req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+	xr := req.GetObserved().GetComposite().GetResource().AsMap()
+	spec, ok := xr["spec"].(map[string]any)
+	if !ok {
+		return nil, errors.New("composite has no spec")
+	}
+	s, err := resource.AsStruct(&unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "example.org/v1",
+		"kind":       "Bucket",
+		"spec":       map[string]any{"region": spec["region"]},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	res := response.To(req, response.DefaultTTL)
+	res.Desired.Resources = map[string]*fnapi.Resource{"bucket": {Resource: s}}
+	response.Normalf(res, "composed bucket")
+	return res, nil
+}
+
+// evaluateCases returns the names of the failed assertions of each case.
+func evaluateCases(t *testing.T, cases []testCase) map[string][]string {
+	t.Helper()
+	failed := map[string][]string{}
+	for _, c := range cases {
+		var names []string
+		for _, a := range fntesting.EvaluateFunction(c.name, &bucketFunction{}, c.opts...) {
+			if a.Status == fntesting.AssertionFailed {
+				names = append(names, a.Name)
+			}
+		}
+		failed[c.name] = names
+	}
+	return failed
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestLoadSuite(t *testing.T) {
+	path := filepath.Join(writeFiles(t, map[string]string{"suite.yaml": `
+cases:
+  - name: composes-bucket
+    observed:
+      composite: {apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {region: eu}}
+    expect:
+      resources:
+        bucket: {apiVersion: example.org/v1, kind: Bucket, spec: {region: eu}}
+      results:
+        - {severity: SEVERITY_NORMAL, message: composed bucket}
+  - name: wrong-region
+    observed:
+      composite: {apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {region: us}}
+    expect:
+      resources:
+        bucket: {apiVersion: example.org/v1, kind: Bucket, spec: {region: eu}}
+      results:
+        - {severity: SEVERITY_NORMAL, message: composed bucket}
+  - name: no-spec
+    observed:
+      composite: {apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}}
+    expect:
+      error: has no spec
+`}), "suite.yaml")
+
+	cases, err := loadCases(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"composes-bucket": nil,
+		"wrong-region":    {`res.Desired.Resources["bucket"]`},
+		"no-spec":         nil,
+	}
+	if diff := cmp.Diff(want, evaluateCases(t, cases)); diff != "" {
+		t.Errorf("failed assertions: -want +got\n%s", diff)
+	}
+}
+
+func TestLoadSuiteErrors(t *testing.T) {
+	cases := map[string]struct {
+		suite   string
+		wantErr string
+	}{
+		"InvalidYAML": {
+			suite:   "cases: {",
+			wantErr: "cannot unmarshal suite",
+		},
+		"UnknownField": {
+			suite:   "cases: [{name: a, expect: {errror: boom}}]",
+			wantErr: `unknown field "errror"`,
+		},
+		"UnknownTopLevelField": {
+			suite:   "case: [{name: a}]",
+			wantErr: `unknown field "case"`,
+		},
+		"NoCases": {
+			suite:   "cases: []",
+			wantErr: "contains no test cases",
+		},
+		"NoName": {
+			suite:   "cases: [{expect: {error: boom}}]",
+			wantErr: "test case 0 has no name",
+		},
+		"DuplicateName": {
+			suite:   "cases: [{name: a}, {name: a}]",
+			wantErr: `duplicate test case "a"`,
+		},
+		"InvalidResult": {
+			suite:   "cases: [{name: a, expect: {results: [{severity: SEVERITY_UNKNOWN_TO_PROTO}]}}]",
+			wantErr: `invalid test case "a": invalid results: cannot unmarshal result 0`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadSuite(filepath.Join(writeFiles(t, map[string]string{"suite.yaml": c.suite}), "suite.yaml"))
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("want error %q, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadCaseDirs(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"composes-bucket/" + fileObservedComposite: `{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {region: eu}}`,
+		// Like the artifacts of failed tests, expected resources only have
+		// the name annotation as metadata.
+		"composes-bucket/" + fileExpectComposed:   `{apiVersion: example.org/v1, kind: Bucket, metadata: {annotations: {fn.test/resource-name: bucket}}, spec: {region: eu}}`,
+		"composes-bucket/" + fileExpectResults:    `[{severity: SEVERITY_NORMAL, message: composed bucket}]`,
+		"errors/no-spec/" + fileObservedComposite: `{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}}`,
+		"errors/no-spec/" + fileExpectError:       "has no spec\n",
+		"errors/README.md":                        "Not a test case.",
+	})
+
+	cases, err := loadCases(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"composes-bucket": nil,
+		"errors/no-spec":  nil,
+	}
+	if diff := cmp.Diff(want, evaluateCases(t, cases)); diff != "" {
+		t.Errorf("failed assertions: -want +got\n%s", diff)
+	}
+}
+
+func TestLoadCaseDirsErrors(t *testing.T) {
+	cases := map[string]struct {
+		files   map[string]string
+		wantErr string
+	}{
+		"NoCases": {
+			files:   map[string]string{"a/README.md": ""},
+			wantErr: "no test cases found",
+		},
+		"InvalidFixture": {
+			files:   map[string]string{"a/" + fileObservedComposite: "{"},
+			wantErr: "cannot load test case a",
+		},
+		"InvalidResults": {
+			files:   map[string]string{"a/" + fileExpectResults: "{severity: SEVERITY_NORMAL}"},
+			wantErr: "cannot unmarshal " + fileExpectResults,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadCaseDirs(writeFiles(t, c.files))
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("want error %q, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadCasesNotFound(t *testing.T) {
+	if _, err := loadCases(filepath.Join(t.TempDir(), "missing")); err == nil || !strings.Contains(err.Error(), "cannot read test cases") {
+		t.Errorf("want an error for a missing path, got %v", err)
+	}
+}
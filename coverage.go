@@ -0,0 +1,416 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+)
+
+var coverage = &coverageRecorder{}
+
+// coverageRecorder records which parts of a composition the function tests
+// of a package exercise.
+type coverageRecorder struct {
+	mu      sync.Mutex
+	enabled bool
+	tests   int
+
+	// resources by name.
+	resources map[string]*resourceCoverage
+	// compositeFields and inputFields by the kind of the composite or input.
+	compositeFields map[string]*kindCoverage
+	inputFields     map[string]*kindCoverage
+	// results counts the tests that returned a result of a severity or, for
+	// the key "error", an error.
+	results map[string]int
+}
+
+type resourceCoverage struct {
+	// returned is the number of tests the function returned the resource in.
+	returned int
+	// asserted is the number of tests that compared the resource with an
+	// expected one or checked it with ExpectCEL or ExpectJSONPath.
+	asserted int
+}
+
+type kindCoverage struct {
+	// tests is the number of tests with an object of the kind.
+	tests int
+	// fields by field path.
+	fields map[string]*fieldCoverage
+}
+
+type fieldCoverage struct {
+	// values are the distinct JSON encoded values of the field per test. The
+	// values of fields in arrays are joined.
+	values map[string]bool
+	// tests is the number of tests that set the field.
+	tests int
+}
+
+// EnableCoverage starts recording the composition coverage of all subsequent
+// [TestFunction] calls for [WriteCoverageReport]:
+//
+//   - the desired composed resources that were returned by the function and
+//     compared with an expected resource,
+//   - the fields of the observed composite and of the input and whether
+//     they were varied across tests, and
+//   - the result severities and errors that the function returned.
+//
+// Fields in arrays are recorded once for all items, e.g. "spec.tags[*].key".
+// Fields are recorded per kind of the composite and input, so a field is only
+// absent in tests with an object of the same kind. The apiVersion, kind and
+// metadata of the composite and input are ignored.
+func EnableCoverage() {
+	coverage.mu.Lock()
+	defer coverage.mu.Unlock()
+	coverage.enabled = true
+	coverage.resources = map[string]*resourceCoverage{}
+	coverage.compositeFields = map[string]*kindCoverage{}
+	coverage.inputFields = map[string]*kindCoverage{}
+	coverage.results = map[string]int{}
+}
+
+// recordCoverage records the coverage of the test with the response of the
+// function if coverage is enabled.
+func (tc *FunctionTest) recordCoverage(res *fnapi.RunFunctionResponse, err error) {
+	coverage.mu.Lock()
+	defer coverage.mu.Unlock()
+	if !coverage.enabled {
+		return
+	}
+	coverage.tests++
+
+	expected := tc.res.GetDesired().GetResources()
+	returned := res.GetDesired().GetResources()
+	for _, name := range maps.SortedKeys(returned) {
+		rc := coverage.resource(name)
+		rc.returned++
+		if _, exists := expected[name]; exists || tc.checkedResources[name] {
+			rc.asserted++
+		}
+	}
+	for _, name := range maps.SortedKeys(expected) {
+		coverage.resource(name)
+	}
+
+	if u := convertResourceToUnstructured(tc.req.GetObserved().GetComposite()); u != nil {
+		recordFieldCoverage(coverage.compositeFields, u.Object)
+	}
+	if tc.req.GetInput() != nil {
+		recordFieldCoverage(coverage.inputFields, tc.req.GetInput().AsMap())
+	}
+
+	severities := map[string]bool{}
+	for _, r := range res.GetResults() {
+		severities[r.GetSeverity().String()] = true
+	}
+	if err != nil {
+		severities["error"] = true
+	}
+	for s := range severities {
+		coverage.results[s]++
+	}
+}
+
+// checkResources records that a check asserted the desired composed resources
+// with the given names for the coverage.
+func (tc *FunctionTest) checkResources(names ...string) {
+	if tc.checkedResources == nil {
+		tc.checkedResources = map[string]bool{}
+	}
+	for _, name := range names {
+		tc.checkedResources[name] = true
+	}
+}
+
+func (r *coverageRecorder) resource(name string) *resourceCoverage {
+	rc, exists := r.resources[name]
+	if !exists {
+		rc = &resourceCoverage{}
+		r.resources[name] = rc
+	}
+	return rc
+}
+
+// recordFieldCoverage adds the values of all fields of obj to the fields of
+// its kind.
+func recordFieldCoverage(kinds map[string]*kindCoverage, obj map[string]any) {
+	kind := (&unstructured.Unstructured{Object: obj}).GroupVersionKind().GroupKind().String()
+	kc, exists := kinds[kind]
+	if !exists {
+		kc = &kindCoverage{fields: map[string]*fieldCoverage{}}
+		kinds[kind] = kc
+	}
+	kc.tests++
+
+	values := map[string]map[string]bool{}
+	for k, v := range obj {
+		if k == "apiVersion" || k == "kind" || k == "metadata" {
+			continue
+		}
+		collectFieldValues(values, joinMapPath("", k), v)
+	}
+	for path, vals := range values {
+		fc, exists := kc.fields[path]
+		if !exists {
+			fc = &fieldCoverage{values: map[string]bool{}}
+			kc.fields[path] = fc
+		}
+		fc.tests++
+		fc.values[strings.Join(maps.SortedKeys(vals), ",")] = true
+	}
+}
+
+// collectFieldValues adds the JSON encoded values of all leaf fields of v to
+// values. Empty objects and arrays are leaves, too.
+func collectFieldValues(values map[string]map[string]bool, path string, v any) {
+	switch vv := v.(type) {
+	case map[string]any:
+		if len(vv) > 0 {
+			for k, e := range vv {
+				collectFieldValues(values, joinMapPath(path, k), e)
+			}
+			return
+		}
+	case []any:
+		if len(vv) > 0 {
+			for _, e := range vv {
+				collectFieldValues(values, path+"[*]", e)
+			}
+			return
+		}
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		raw = []byte(fmt.Sprint(v))
+	}
+	if values[path] == nil {
+		values[path] = map[string]bool{}
+	}
+	values[path][string(raw)] = true
+}
+
+// coverageSummary is the evaluated coverage that is rendered by
+// [WriteCoverageReport].
+type coverageSummary struct {
+	Tests           int
+	Resources       []resourceSummary
+	CompositeFields []fieldSummary
+	InputFields     []fieldSummary
+	Results         []resultSummary
+}
+
+type resourceSummary struct {
+	Name     string
+	Returned int
+	Asserted int
+}
+
+func (s resourceSummary) Covered() bool { return s.Asserted > 0 }
+
+type fieldSummary struct {
+	// Kind is the kind of the object as "Kind.group", which is empty if the
+	// object has no apiVersion and kind.
+	Kind string
+	Path string
+	// Values is the number of distinct values of the field across tests.
+	Values int
+	// Absent is true if some tests with an object of the same kind did not
+	// set the field, which counts as additional value.
+	Absent bool
+}
+
+func (s fieldSummary) Covered() bool {
+	return s.Values > 1 || (s.Values == 1 && s.Absent)
+}
+
+type resultSummary struct {
+	Kind  string
+	Tests int
+}
+
+func (s resultSummary) Covered() bool { return s.Tests > 0 }
+
+func countCovered[T interface{ Covered() bool }](items []T) int {
+	n := 0
+	for _, i := range items {
+		if i.Covered() {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *coverageRecorder) summary() coverageSummary {
+	s := coverageSummary{Tests: r.tests}
+	for _, name := range maps.SortedKeys(r.resources) {
+		rc := r.resources[name]
+		s.Resources = append(s.Resources, resourceSummary{Name: name, Returned: rc.returned, Asserted: rc.asserted})
+	}
+	fieldSummaries := func(kinds map[string]*kindCoverage) []fieldSummary {
+		out := []fieldSummary{}
+		for _, kind := range maps.SortedKeys(kinds) {
+			kc := kinds[kind]
+			for _, path := range maps.SortedKeys(kc.fields) {
+				fc := kc.fields[path]
+				out = append(out, fieldSummary{Kind: kind, Path: path, Values: len(fc.values), Absent: fc.tests < kc.tests})
+			}
+		}
+		return out
+	}
+	s.CompositeFields = fieldSummaries(r.compositeFields)
+	s.InputFields = fieldSummaries(r.inputFields)
+
+	kinds := []string{}
+	for v, name := range fnapi.Severity_name {
+		if v != int32(fnapi.Severity_SEVERITY_UNSPECIFIED) {
+			kinds = append(kinds, name)
+		}
+	}
+	sort.Strings(kinds)
+	for _, k := range append(kinds, "error") {
+		s.Results = append(s.Results, resultSummary{Kind: k, Tests: r.results[k]})
+	}
+	return s
+}
+
+// WriteCoverageReport writes the composition coverage recorded since
+// [EnableCoverage] was called to path. The report is written as HTML if path
+// has the extension ".html" and as text otherwise. It is typically called
+// from TestMain after the tests ran:
+//
+//	func TestMain(m *testing.M) {
+//		fntesting.EnableCoverage()
+//		code := m.Run()
+//		if err := fntesting.WriteCoverageReport("composition-coverage.html"); err != nil {
+//			fmt.Println(err)
+//		}
+//		os.Exit(code)
+//	}
+//
+// A resource is covered if a test compared it with an expected resource or
+// checked it with [ExpectCEL] or [ExpectJSONPath], a field if it had at least
+// two different values or was not set in some tests with the same kind of
+// object and a result severity if a function returned it. Calls of
+// [TestFunctionGetResult] and [TestFunctionGetResponse] are not recorded, so
+// resources asserted with the [Response] API are not covered.
+func WriteCoverageReport(path string) error {
+	coverage.mu.Lock()
+	s := coverage.summary()
+	coverage.mu.Unlock()
+
+	var raw []byte
+	if filepath.Ext(path) == ".html" {
+		buf := &bytes.Buffer{}
+		if err := coverageHTML.Execute(buf, s); err != nil {
+			return errors.Wrap(err, "cannot render coverage report")
+		}
+		raw = buf.Bytes()
+	} else {
+		raw = []byte(s.text())
+	}
+	return errors.Wrap(os.WriteFile(path, raw, 0o644), "cannot write coverage report") //nolint:gosec // Reports are meant to be read.
+}
+
+func (s coverageSummary) text() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Composition coverage of %d function tests\n", s.Tests)
+
+	fmt.Fprintf(b, "\nDesired composed resources: %d/%d asserted\n", countCovered(s.Resources), len(s.Resources))
+	for _, r := range s.Resources {
+		fmt.Fprintf(b, "  %s %s: asserted in %d, returned in %s\n", coverageMark(r), r.Name, r.Asserted, countNoun(r.Returned, "test"))
+	}
+
+	writeFields := func(title string, fields []fieldSummary) {
+		fmt.Fprintf(b, "\n%s: %d/%d varied\n", title, countCovered(fields), len(fields))
+		for _, f := range fields {
+			absent := ""
+			if f.Absent {
+				absent = " and absent"
+			}
+			path := f.Path
+			if f.Kind != "" {
+				path = f.Kind + " " + path
+			}
+			fmt.Fprintf(b, "  %s %s: %s%s\n", coverageMark(f), path, countNoun(f.Values, "value"), absent)
+		}
+	}
+	writeFields("Observed composite fields", s.CompositeFields)
+	writeFields("Input fields", s.InputFields)
+
+	fmt.Fprintf(b, "\nResults: %d/%d returned\n", countCovered(s.Results), len(s.Results))
+	for _, r := range s.Results {
+		fmt.Fprintf(b, "  %s %s: %s\n", coverageMark(r), r.Kind, countNoun(r.Tests, "test"))
+	}
+	return b.String()
+}
+
+func countNoun(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func coverageMark(c interface{ Covered() bool }) string {
+	if c.Covered() {
+		return "+"
+	}
+	return "-"
+}
+
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Composition coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+td.path { font-family: monospace; }
+tr.covered { background: #dfd; }
+tr.uncovered { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Composition coverage of {{.Tests}} function tests</h1>
+<h2>Desired composed resources</h2>
+<table>
+<tr><th>Name</th><th>Asserted in tests</th><th>Returned in tests</th></tr>
+{{range .Resources}}<tr class="{{if .Covered}}covered{{else}}uncovered{{end}}"><td class="path">{{.Name}}</td><td>{{.Asserted}}</td><td>{{.Returned}}</td></tr>
+{{end}}</table>
+<h2>Observed composite fields</h2>
+<table>
+<tr><th>Kind</th><th>Field</th><th>Values</th><th>Absent in tests</th></tr>
+{{range .CompositeFields}}<tr class="{{if .Covered}}covered{{else}}uncovered{{end}}"><td>{{.Kind}}</td><td class="path">{{.Path}}</td><td>{{.Values}}</td><td>{{if .Absent}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>
+<h2>Input fields</h2>
+<table>
+<tr><th>Kind</th><th>Field</th><th>Values</th><th>Absent in tests</th></tr>
+{{range .InputFields}}<tr class="{{if .Covered}}covered{{else}}uncovered{{end}}"><td>{{.Kind}}</td><td class="path">{{.Path}}</td><td>{{.Values}}</td><td>{{if .Absent}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>
+<h2>Results</h2>
+<table>
+<tr><th>Severity</th><th>Returned in tests</th></tr>
+{{range .Results}}<tr class="{{if .Covered}}covered{{else}}uncovered{{end}}"><td>{{.Kind}}</td><td>{{.Tests}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
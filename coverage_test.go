@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/response"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// enableTestCoverage records the coverage of the test in a new recorder.
+func enableTestCoverage(t *testing.T) {
+	t.Helper()
+	previous := coverage
+	coverage = &coverageRecorder{}
+	EnableCoverage()
+	t.Cleanup(func() { coverage = previous })
+}
+
+// coveredFunction composes the same resources and a result for every request
+// or fails if the composite sets spec.fail.
+func coveredFunction() *testFunction {
+	return &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		xr := req.GetObserved().GetComposite().GetResource().AsMap()
+		if spec, _ := xr["spec"].(map[string]any); spec["fail"] == true {
+			return nil, errors.New("boom")
+		}
+		res := response.To(req, response.DefaultTTL)
+		res.Desired.Resources = map[string]*fnapi.Resource{}
+		for name, kind := range map[string]string{"bucket": "Bucket", "policy": "BucketPolicy", "table": "Table", "extra": "Extra"} {
+			res.Desired.Resources[name] = &fnapi.Resource{Resource: mustObjectAsStruct(&unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       kind,
+			}})}
+		}
+		response.Normal(res, "composed")
+		return res, nil
+	}}
+}
+
+func runCoveredTests(t *testing.T) {
+	t.Helper()
+	fn := coveredFunction()
+	composed := ExpectResults([]*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_NORMAL, Message: "composed"}})
+	tests := map[string][]TestFunctionOpt{
+		"ExpectedResource": {
+			WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {region: eu, size: 1}}`)),
+			WithInputYAML([]byte(`{apiVersion: example.org/v1beta1, kind: Input, mode: a}`)),
+			ExpectDesiredResourceYAML("bucket", []byte(`{apiVersion: example.org/v1, kind: Bucket}`)),
+			composed,
+		},
+		"CEL": {
+			WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {region: us}}`)),
+			WithInputYAML([]byte(`{apiVersion: example.org/v1beta1, kind: Input, mode: b}`)),
+			ExpectCEL("pol*", `object.kind == "BucketPolicy"`),
+			composed,
+		},
+		"JSONPath": {
+			WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XDatabase, metadata: {name: xr}, spec: {engine: postgres}}`)),
+			ExpectJSONPath("table", ".kind", "Table"),
+			composed,
+		},
+		"Error": {
+			WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {fail: true}}`)),
+			ExpectErrorContains("boom"),
+		},
+	}
+	for name, opts := range tests {
+		if failed := failedAssertions(EvaluateFunction(name, fn, append(opts, AllowExtraDesiredResources())...)); len(failed) > 0 {
+			t.Fatalf("%s: failed assertions %q", name, failed)
+		}
+	}
+}
+
+func TestCoverageSummary(t *testing.T) {
+	enableTestCoverage(t)
+	runCoveredTests(t)
+
+	want := coverageSummary{
+		Tests: 4,
+		Resources: []resourceSummary{
+			{Name: "bucket", Returned: 3, Asserted: 1},
+			{Name: "extra", Returned: 3, Asserted: 0},
+			{Name: "policy", Returned: 3, Asserted: 1},
+			{Name: "table", Returned: 3, Asserted: 1},
+		},
+		CompositeFields: []fieldSummary{
+			// Absent in one of three tests of an XBucket.
+			{Kind: "XBucket.example.org", Path: "spec.fail", Values: 1, Absent: true},
+			{Kind: "XBucket.example.org", Path: "spec.region", Values: 2, Absent: true},
+			{Kind: "XBucket.example.org", Path: "spec.size", Values: 1, Absent: true},
+			// Set in the only test of an XDatabase.
+			{Kind: "XDatabase.example.org", Path: "spec.engine", Values: 1, Absent: false},
+		},
+		InputFields: []fieldSummary{
+			// Set in both tests with an input.
+			{Kind: "Input.example.org", Path: "mode", Values: 2, Absent: false},
+		},
+		Results: []resultSummary{
+			{Kind: "SEVERITY_FATAL", Tests: 0},
+			{Kind: "SEVERITY_NORMAL", Tests: 3},
+			{Kind: "SEVERITY_WARNING", Tests: 0},
+			{Kind: "error", Tests: 1},
+		},
+	}
+	if diff := cmp.Diff(want, coverage.summary()); diff != "" {
+		t.Errorf("summary: -want +got\n%s", diff)
+	}
+}
+
+func TestWriteCoverageReport(t *testing.T) {
+	enableTestCoverage(t)
+	runCoveredTests(t)
+	dir := t.TempDir()
+
+	cases := map[string]struct {
+		file string
+		want []string
+	}{
+		"Text": {
+			file: "coverage.txt",
+			want: []string{
+				"Composition coverage of 4 function tests\n",
+				"Desired composed resources: 3/4 asserted\n",
+				"  - extra: asserted in 0, returned in 3 tests\n",
+				"  + policy: asserted in 1, returned in 3 tests\n",
+				"Observed composite fields: 3/4 varied\n",
+				"  + XBucket.example.org spec.region: 2 values and absent\n",
+				"  - XDatabase.example.org spec.engine: 1 value\n",
+				"Input fields: 1/1 varied\n",
+				"  + Input.example.org mode: 2 values\n",
+				"Results: 2/4 returned\n",
+				"  - SEVERITY_FATAL: 0 tests\n",
+				"  + error: 1 test\n",
+			},
+		},
+		"HTML": {
+			file: "coverage.html",
+			want: []string{
+				"<h1>Composition coverage of 4 function tests</h1>",
+				`<tr class="uncovered"><td class="path">extra</td><td>0</td><td>3</td></tr>`,
+				`<tr class="covered"><td>XBucket.example.org</td><td class="path">spec.region</td><td>2</td><td>yes</td></tr>`,
+				`<tr class="uncovered"><td>XDatabase.example.org</td><td class="path">spec.engine</td><td>1</td><td>no</td></tr>`,
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, c.file)
+			if err := WriteCoverageReport(path); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range c.want {
+				if !strings.Contains(string(raw), want) {
+					t.Errorf("want %q in report:\n%s", want, raw)
+				}
+			}
+		})
+	}
+}
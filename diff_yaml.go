@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/diff"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+const (
+	// EnvColor is the environment variable that controls whether YAML diffs
+	// are colored. It is either "always", "never" or "auto", which colors
+	// diffs if the standard output is a terminal.
+	EnvColor = "FNTEST_COLOR"
+
+	// yamlDiffContext is the number of unchanged lines shown around changes.
+	yamlDiffContext = 3
+)
+
+// WithYAMLDiffs reports differences between the expected and the actual
+// desired composite and composed resources as unified diffs of their YAML
+// representation, each with the paths of all changed fields. Names of missing
+// and unexpected composed resources are summarized before the diffs.
+//
+// The diffs are colored when they are reported to a terminal and the
+// environment variable NO_COLOR is not set. Set [EnvColor] to override this.
+// Reports written by [WriteJUnitReport] and [WriteJSONReport] are never
+// colored.
+func WithYAMLDiffs() TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.yamlDiffs = true
+	}
+}
+
+// compareDesiredYAML compares the desired state of res to the expected one
+// and reports differences as YAML diffs.
+func (tc *FunctionTest) compareDesiredYAML(res *fnapi.RunFunctionResponse) []Assertion {
+	assertions := []Assertion{passedAssertion("res.Desired.Composite")}
+	if d := yamlDiff(convertResourceToUnstructured(tc.res.GetDesired().GetComposite()), convertResourceToUnstructured(res.GetDesired().GetComposite())); d != "" {
+		assertions[0] = failedAssertion("res.Desired.Composite", "-want +got", d)
+	}
+
+	want := convertResourcesMapToUnstructured(tc.res.GetDesired().GetResources())
+	got := convertResourcesMapToUnstructured(res.GetDesired().GetResources())
+	names, a := tc.compareDesiredResourceNames(want, got)
+	assertions = append(assertions, a)
+	for _, name := range names {
+		a := passedAssertion(fmt.Sprintf("res.Desired.Resources[%q]", name))
+		if d := yamlDiff(want[name], got[name]); d != "" {
+			a = failedAssertion(a.Name, "-want +got", d)
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions
+}
+
+// diffResourceNames returns the sorted names of all resources that are in
+// want but not in got and vice versa.
+func diffResourceNames[V any](want, got map[string]V) (missing, unexpected []string) {
+	for _, name := range maps.SortedKeys(want) {
+		if _, exists := got[name]; !exists {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range maps.SortedKeys(got) {
+		if _, exists := want[name]; !exists {
+			unexpected = append(unexpected, name)
+		}
+	}
+	return missing, unexpected
+}
+
+func formatResourceNameDiff(missing, unexpected []string) string {
+	parts := []string{}
+	if len(missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing: %s", strings.Join(missing, ", ")))
+	}
+	if len(unexpected) > 0 {
+		parts = append(parts, fmt.Sprintf("unexpected: %s", strings.Join(unexpected, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// yamlDiff returns the paths of all fields that differ between want and got
+// followed by the unified diff of their YAML representations. It returns an
+// empty string if both are equal.
+func yamlDiff(want, got *unstructured.Unstructured) string {
+	var wantObj, gotObj map[string]any
+	if want != nil {
+		wantObj = want.Object
+	}
+	if got != nil {
+		gotObj = got.Object
+	}
+	paths := jsonDiffPaths(normalizeDiffValue(wantObj), normalizeDiffValue(gotObj), "")
+	if len(paths) == 0 {
+		return ""
+	}
+	lines := diff.Lines(yamlLines(wantObj), yamlLines(gotObj))
+	return fmt.Sprintf("changed fields: %s\n%s", strings.Join(paths, ", "), diff.Unified(lines, yamlDiffContext))
+}
+
+// normalizeDiffValue returns v as generic JSON value, so numbers of different
+// types are compared by their value. A nil map is returned as nil.
+func normalizeDiffValue(v map[string]any) any {
+	if v == nil {
+		return nil
+	}
+	n, err := normalizeJSONValue(v)
+	if err != nil {
+		return v
+	}
+	return n
+}
+
+func yamlLines(obj map[string]any) []string {
+	if obj == nil {
+		return nil
+	}
+	raw, err := yaml.Marshal(obj)
+	if err != nil {
+		return []string{fmt.Sprintf("# cannot marshal object: %s", err)}
+	}
+	return strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+}
+
+// diffColor returns whether diffs reported to the test are colored.
+func diffColor() bool {
+	return diff.UseColor(os.Stdout, os.Getenv(EnvColor))
+}
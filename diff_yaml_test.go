@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestYAMLDiff(t *testing.T) {
+	bucket := func(spec map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{"apiVersion": "example.org/v1", "kind": "Bucket", "spec": spec}}
+	}
+	cases := map[string]struct {
+		want, got *unstructured.Unstructured
+		diff      string
+	}{
+		"Equal": {
+			want: bucket(map[string]any{"region": "eu"}),
+			got:  bucket(map[string]any{"region": "eu"}),
+		},
+		"NumbersOfDifferentTypes": {
+			want: bucket(map[string]any{"size": int64(1)}),
+			got:  bucket(map[string]any{"size": float64(1)}),
+		},
+		"Changed": {
+			want: bucket(map[string]any{"region": "eu", "size": int64(1)}),
+			got:  bucket(map[string]any{"region": "us", "size": int64(1)}),
+			diff: "changed fields: spec.region\n@@ -1,5 +1,5 @@\n apiVersion: example.org/v1\n kind: Bucket\n spec:\n-  region: eu\n+  region: us\n   size: 1\n",
+		},
+		"Missing": {
+			want: bucket(map[string]any{}),
+			diff: "changed fields: <root>\n@@ -1,3 +0,0 @@\n-apiVersion: example.org/v1\n-kind: Bucket\n-spec: {}\n",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(c.diff, yamlDiff(c.want, c.got)); diff != "" {
+				t.Errorf("yamlDiff(...): -want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithYAMLDiffsPlainAssertions(t *testing.T) {
+	t.Setenv(EnvColor, "always")
+	fn := desiredResourcesFunction(t, map[string]map[string]any{
+		"bucket": {"apiVersion": "example.org/v1", "kind": "Bucket", "spec": map[string]any{"region": "us"}},
+	})
+	assertions := EvaluateFunction(t.Name(), fn, WithYAMLDiffs(),
+		ExpectDesiredResourceYAML("bucket", []byte(`{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu}}`)))
+	i := slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == `res.Desired.Resources["bucket"]` })
+	if i < 0 || assertions[i].Status != AssertionFailed {
+		t.Fatalf("want a failed assertion of the bucket, got %v", assertions)
+	}
+	if d := assertions[i].Diff; !strings.Contains(d, "-  region: eu\n+  region: us\n") || strings.Contains(d, "\x1b") {
+		t.Errorf("want a plain YAML diff, got %q", d)
+	}
+}
@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/fuzz"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/schema"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+// fuzzSeeds are added to the seed corpus of every fuzz test. The empty seed
+// runs the function with the unmodified request.
+var fuzzSeeds = [][]byte{
+	{},
+	bytes.Repeat([]byte{0x01}, 16),
+	bytes.Repeat([]byte{0x02, 0xff}, 16),
+	bytes.Repeat([]byte{0x03, 0x7f, 0x10}, 16),
+}
+
+// FuzzFunction fuzzes fn with the request that is built from opts. For every
+// fuzz input, fields of the observed composite, the input and the context of
+// the request are removed or set to new values.
+//
+// If schemas are loaded with [WithXRD] or [WithCRDs], new values of the
+// observed composite and the input are generated from their schemas, so they
+// are mostly valid. Defaults of the schema are applied to the observed
+// composite. Otherwise, only the values of existing fields are changed to
+// values of the same type.
+//
+// The fuzz test fails if
+//   - the function panics,
+//   - the function returns an error or a fatal result although the request
+//     is schema-valid, i.e. the observed composite and every mutated part of
+//     the request have a schema and are valid against it,
+//   - a desired composed resource has no name, apiVersion or kind.
+//
+// Expectations configured by opts are ignored.
+func FuzzFunction(f *testing.F, fn fnapi.FunctionRunnerServiceServer, opts ...TestFunctionOpt) {
+	f.Helper()
+	tc := generateTc(fn)
+
+	// Apply user options
+	for _, o := range opts {
+		o(tc)
+	}
+	if err := tc.setup(); err != nil {
+		f.Fatal(errors.Wrapf(err, "cannot set up fuzz test"))
+	}
+
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req := proto.Clone(tc.req).(*fnapi.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+		valid, err := tc.mutateRequest(req, fuzz.NewSource(data))
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "cannot mutate request"))
+		}
+
+		res, err := tc.runFunction(proto.Clone(req).(*fnapi.RunFunctionRequest)) //nolint:forcetypeassert // Clone returns the same type.
+		for _, v := range fuzzViolations(res, err, valid) {
+			t.Error(v)
+		}
+		if t.Failed() {
+			t.Logf("Mutated request:\n%s", formatFuzzRequest(req))
+		}
+	})
+}
+
+// mutatedStruct is a part of a request that is mutated by a fuzz test.
+type mutatedStruct struct {
+	desc   string
+	s      *structpb.Struct
+	schema *structuralschema.Structural
+	skip   []string
+}
+
+// mutateRequest mutates the observed composite, input and context of req in
+// place and returns whether the result is schema-valid.
+func (tc *FunctionTest) mutateRequest(req *fnapi.RunFunctionRequest, src *fuzz.Source) (bool, error) {
+	composite := req.GetObserved().GetComposite()
+	compositeSchema := tc.structuralSchema(composite.GetResource())
+	parts := []mutatedStruct{
+		{desc: "observed composite", s: composite.GetResource(), schema: compositeSchema, skip: []string{"apiVersion", "kind", "metadata"}},
+		{desc: "input", s: req.GetInput(), schema: tc.structuralSchema(req.GetInput()), skip: []string{"apiVersion", "kind", "metadata"}},
+		{desc: "context", s: req.GetContext()},
+	}
+
+	valid := compositeSchema != nil
+	for _, p := range parts {
+		if p.s == nil {
+			continue
+		}
+		obj := p.s.AsMap()
+		if fuzz.Mutate(obj, p.schema, src, p.skip...) == 0 {
+			continue
+		}
+		if p.schema == nil {
+			valid = false
+		}
+		mutated, err := structpb.NewStruct(obj)
+		if err != nil {
+			return false, errors.Wrap(err, p.desc)
+		}
+		p.s.Fields = mutated.GetFields()
+	}
+
+	if composite != nil && compositeSchema != nil {
+		u := convertResourceToUnstructured(composite)
+		tc.schemas.Default(u)
+		composite.Resource = mustObjectAsStruct(u)
+	}
+	for _, s := range []*structpb.Struct{composite.GetResource(), req.GetInput()} {
+		if valid && tc.structuralSchema(s) != nil && !tc.isSchemaValid(s) {
+			valid = false
+		}
+	}
+	return valid, nil
+}
+
+// structuralSchema returns the structural schema of the object s or nil if
+// no schema is loaded for it.
+func (tc *FunctionTest) structuralSchema(s *structpb.Struct) *structuralschema.Structural {
+	if s == nil || tc.schemas == nil {
+		return nil
+	}
+	u := &unstructured.Unstructured{Object: s.AsMap()}
+	return tc.schemas.Structural(u.GroupVersionKind())
+}
+
+func (tc *FunctionTest) isSchemaValid(s *structpb.Struct) bool {
+	errs, err := tc.schemas.Validate(&unstructured.Unstructured{Object: s.AsMap()}, schema.ValidateOptions{})
+	return err == nil && len(errs) == 0
+}
+
+// fuzzViolations returns all properties that the function run violates.
+func fuzzViolations(res *fnapi.RunFunctionResponse, err error, valid bool) []string {
+	var p *functionPanic
+	if errors.As(err, &p) {
+		return []string{fmt.Sprintf("Function panicked: %v\n\n%s", p.value, p.stack)}
+	}
+	if err != nil {
+		if valid {
+			return []string{fmt.Sprintf("Function returned an error for a schema-valid request: %s", err)}
+		}
+		return nil
+	}
+
+	violations := []string{}
+	for _, r := range res.GetResults() {
+		if valid && r.GetSeverity() == fnapi.Severity_SEVERITY_FATAL {
+			violations = append(violations, fmt.Sprintf("Function returned a fatal result for a schema-valid request: %s", r.GetMessage()))
+		}
+	}
+	resources := res.GetDesired().GetResources()
+	for _, name := range maps.SortedKeys(resources) {
+		desc := fmt.Sprintf("Desired resource %q", name)
+		if name == "" {
+			desc = "Desired resource without name"
+		}
+		u := convertResourceToUnstructured(resources[name])
+		var missing []string
+		if u.GetAPIVersion() == "" {
+			missing = append(missing, "apiVersion")
+		}
+		if u.GetKind() == "" {
+			missing = append(missing, "kind")
+		}
+		switch {
+		case len(missing) > 0:
+			violations = append(violations, fmt.Sprintf("%s has no %s", desc, strings.Join(missing, " and ")))
+		case name == "":
+			violations = append(violations, fmt.Sprintf("%s (%s)", desc, u.GroupVersionKind().String()))
+		}
+	}
+	return violations
+}
+
+// formatFuzzRequest returns the parts of req that are mutated by a fuzz test
+// as YAML.
+func formatFuzzRequest(req *fnapi.RunFunctionRequest) string {
+	b := &strings.Builder{}
+	parts := []struct {
+		desc string
+		s    *structpb.Struct
+	}{
+		{desc: "observed composite", s: req.GetObserved().GetComposite().GetResource()},
+		{desc: "input", s: req.GetInput()},
+		{desc: "context", s: req.GetContext()},
+	}
+	for _, p := range parts {
+		if p.s == nil {
+			continue
+		}
+		raw, err := yaml.Marshal(p.s.AsMap())
+		if err != nil {
+			fmt.Fprintf(b, "# %s: %s\n", p.desc, err)
+			continue
+		}
+		fmt.Fprintf(b, "# %s\n%s", p.desc, raw)
+	}
+	return b.String()
+}
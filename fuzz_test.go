@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/response"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/fuzz"
+)
+
+// FuzzFunctionRobust runs the seed corpus of FuzzFunction against a function
+// that handles every request.
+func FuzzFunctionRobust(f *testing.F) {
+	fn := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		res := response.To(req, response.DefaultTTL)
+		spec, _ := req.GetObserved().GetComposite().GetResource().AsMap()["spec"].(map[string]any)
+		if _, ok := spec["region"].(string); !ok {
+			response.Fatal(res, errors.New("spec.region is not a string"))
+		}
+		return res, nil
+	}}
+	FuzzFunction(f, fn,
+		WithXRD(testXRD),
+		WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {region: eu}}`)),
+		WithContextValue("key", "value"),
+	)
+}
+
+func TestMutateRequest(t *testing.T) {
+	tc := generateTc(nil)
+	WithXRD(testXRD)(tc)
+	WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {region: eu}}`))(tc)
+	WithContextValue("key", "value")(tc)
+	if err := tc.setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewPCG(1, 1)) //nolint:gosec // No need for cryptographic randomness.
+	sawValid, sawInvalid := false, false
+	for i := range 100 {
+		data := make([]byte, 256)
+		for j := range data {
+			data[j] = byte(rnd.Uint32())
+		}
+		req := proto.Clone(tc.req).(*fnapi.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+		valid, err := tc.mutateRequest(req, fuzz.NewSource(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := convertResourceToUnstructured(req.GetObserved().GetComposite())
+		if u.GetAPIVersion() != "example.org/v1" || u.GetKind() != "XBucket" || u.GetName() != "xr" {
+			t.Fatalf("run %d: apiVersion, kind or metadata of the composite were mutated: %v", i, u.Object)
+		}
+		// The context has no schema, so mutating it makes the request
+		// invalid.
+		if valid && !proto.Equal(req.GetContext(), tc.req.GetContext()) {
+			t.Errorf("run %d: request with mutated context is reported as valid", i)
+		}
+		if valid && !tc.isSchemaValid(req.GetObserved().GetComposite().GetResource()) {
+			t.Errorf("run %d: request with invalid composite is reported as valid", i)
+		}
+		sawValid = sawValid || valid
+		sawInvalid = sawInvalid || !valid
+	}
+	if !sawValid || !sawInvalid {
+		t.Errorf("want valid and invalid mutated requests, got valid: %t, invalid: %t", sawValid, sawInvalid)
+	}
+}
+
+func TestFuzzViolations(t *testing.T) {
+	withResources := func(resources map[string]map[string]any) *fnapi.RunFunctionResponse {
+		res := &fnapi.RunFunctionResponse{Desired: &fnapi.State{Resources: map[string]*fnapi.Resource{}}}
+		for name, o := range resources {
+			s, err := structpb.NewStruct(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Desired.Resources[name] = &fnapi.Resource{Resource: s}
+		}
+		return res
+	}
+	fatal := &fnapi.RunFunctionResponse{Results: []*fnapi.Result{{Severity: fnapi.Severity_SEVERITY_FATAL, Message: "boom"}}}
+
+	cases := map[string]struct {
+		res   *fnapi.RunFunctionResponse
+		err   error
+		valid bool
+		want  []string
+	}{
+		"Panic": {
+			err:  &functionPanic{value: "boom"},
+			want: []string{"Function panicked: boom"},
+		},
+		"ErrorForValidRequest": {
+			err:   errors.New("boom"),
+			valid: true,
+			want:  []string{"Function returned an error for a schema-valid request: boom"},
+		},
+		"ErrorForInvalidRequest": {
+			err: errors.New("boom"),
+		},
+		"FatalResultForValidRequest": {
+			res:   fatal,
+			valid: true,
+			want:  []string{"Function returned a fatal result for a schema-valid request: boom"},
+		},
+		"FatalResultForInvalidRequest": {
+			res: fatal,
+		},
+		"IncompleteResources": {
+			res: withResources(map[string]map[string]any{
+				"":       {"apiVersion": "v1", "kind": "ConfigMap"},
+				"bucket": {"kind": "Bucket"},
+				"valid":  {"apiVersion": "v1", "kind": "ConfigMap"},
+			}),
+			want: []string{
+				"Desired resource without name (/v1, Kind=ConfigMap)",
+				`Desired resource "bucket" has no apiVersion`,
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := fuzzViolations(c.res, c.err, c.valid)
+			if len(got) != len(c.want) {
+				t.Fatalf("want violations %q, got %q", c.want, got)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], c.want[i]) {
+					t.Errorf("want violation %d to start with %q, got %q", i, c.want[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ package testing
 import (
 	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
 	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -57,6 +58,23 @@ func convertResourceToUnstructured(r *fnapi.Resource) *unstructured.Unstructured
 	return u
 }
 
+// resourceAsUnstructured is like convertResourceToUnstructured but returns an
+// error if r is malformed, i.e. cannot be converted or has no apiVersion or
+// kind.
+func resourceAsUnstructured(r *fnapi.Resource) (*unstructured.Unstructured, error) {
+	if r == nil {
+		return nil, nil
+	}
+	u := &unstructured.Unstructured{}
+	if err := resource.AsObject(r.GetResource(), u); err != nil {
+		return nil, err
+	}
+	if u.GetAPIVersion() == "" || u.GetKind() == "" {
+		return nil, errors.New("resource has no apiVersion or kind")
+	}
+	return u, nil
+}
+
 func ConvertDesiredCompositeToObject(r *fnapi.RunFunctionResponse, o runtime.Object) {
 	if err := resource.AsObject(r.GetDesired().GetComposite().GetResource(), o); err != nil {
 		panic(err)
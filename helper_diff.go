@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+)
+
+// protoDiffPaths returns the paths of all fields that differ between a and b
+// based on their JSON representation.
+func protoDiffPaths(a, b proto.Message) ([]string, error) {
+	aJSON, err := protoAsJSONValue(a)
+	if err != nil {
+		return nil, err
+	}
+	bJSON, err := protoAsJSONValue(b)
+	if err != nil {
+		return nil, err
+	}
+	return jsonDiffPaths(aJSON, bJSON, ""), nil
+}
+
+func protoAsJSONValue(m proto.Message) (any, error) {
+	raw, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal message")
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal message")
+	}
+	return out, nil
+}
+
+// jsonDiffPaths returns the sorted paths of all values that differ between
+// the generic JSON values a and b. Paths use the field path syntax of
+// Crossplane, e.g. "spec.forProvider.tags[0]".
+func jsonDiffPaths(a, b any, prefix string) []string {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			return []string{pathOrRoot(prefix)}
+		}
+		keys := map[string]bool{}
+		for k := range av {
+			keys[k] = true
+		}
+		for k := range bv {
+			keys[k] = true
+		}
+		paths := []string{}
+		for _, k := range maps.SortedKeys(keys) {
+			paths = append(paths, jsonDiffPaths(av[k], bv[k], joinMapPath(prefix, k))...)
+		}
+		return paths
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			return []string{pathOrRoot(prefix)}
+		}
+		paths := []string{}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			p := fmt.Sprintf("%s[%d]", prefix, i)
+			if i >= len(av) || i >= len(bv) {
+				paths = append(paths, p)
+				continue
+			}
+			paths = append(paths, jsonDiffPaths(av[i], bv[i], p)...)
+		}
+		return paths
+	}
+	if !reflect.DeepEqual(a, b) {
+		return []string{pathOrRoot(prefix)}
+	}
+	return nil
+}
+
+// joinMapPath appends the map key k to the path prefix. Keys that are not
+// valid identifiers are put in brackets.
+func joinMapPath(prefix, k string) string {
+	if !isFieldPathIdentifier(k) {
+		return fmt.Sprintf("%s[%s]", prefix, k)
+	}
+	if prefix == "" {
+		return k
+	}
+	return prefix + "." + k
+}
+
+func isFieldPathIdentifier(k string) bool {
+	if k == "" {
+		return false
+	}
+	for _, r := range k {
+		if r == '.' || r == '[' || r == ']' || r == '/' || r == ' ' {
+			return false
+		}
+	}
+	return true
+}
+
+func pathOrRoot(p string) string {
+	if p == "" {
+		return "<root>"
+	}
+	return p
+}
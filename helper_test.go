@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/resource"
+	"github.com/crossplane/function-sdk-go/response"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testFunction is a function whose behavior is defined by a test.
+type testFunction struct {
+	fnapi.UnimplementedFunctionRunnerServiceServer
+
+	run func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error)
+}
+
+func (f *testFunction) RunFunction(_ context.Context, req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+	return f.run(req)
+}
+
+// desiredResourcesFunction returns a function that adds the given objects as
+// desired composed resources to the response.
+func desiredResourcesFunction(t *testing.T, objects map[string]map[string]any) *testFunction {
+	t.Helper()
+	return &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		res := response.To(req, response.DefaultTTL)
+		res.Desired.Resources = map[string]*fnapi.Resource{}
+		for name, o := range objects {
+			s, err := resource.AsStruct(&unstructured.Unstructured{Object: o})
+			if err != nil {
+				t.Error(err)
+				return nil, err
+			}
+			res.Desired.Resources[name] = &fnapi.Resource{Resource: s}
+		}
+		return res, nil
+	}}
+}
+
+// failedAssertions returns the names of all failed assertions.
+func failedAssertions(assertions []Assertion) []string {
+	var failed []string
+	for _, a := range assertions {
+		if a.Status == AssertionFailed {
+			failed = append(failed, a.Name)
+		}
+	}
+	return failed
+}
+
+// fakeTB records the failures reported to it instead of failing the test.
+// Fatal failures stop the function passed to run like they stop a test. All
+// other methods are passed to the embedded test.
+type fakeTB struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+type fakeTBFatal struct{}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Error(args ...any) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func (t *fakeTB) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Fatal(args ...any) {
+	t.Error(args...)
+	t.fatal = true
+	panic(fakeTBFatal{})
+}
+
+func (t *fakeTB) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	t.fatal = true
+	panic(fakeTBFatal{})
+}
+
+func (t *fakeTB) Failed() bool {
+	return len(t.errors) > 0
+}
+
+// run runs f and recovers from fatal failures reported to t.
+func (t *fakeTB) run(f func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			if _, ok := v.(fakeTBFatal); !ok {
+				panic(v)
+			}
+		}
+	}()
+	f()
+}
@@ -0,0 +1,208 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package fuzz
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// maxDepth is the maximum depth of generated values. Below it, only
+	// required properties of objects are generated.
+	maxDepth = 8
+
+	// maxGeneratedItems is the number of list items and map entries that are
+	// generated in addition to the minimum required by the schema.
+	maxGeneratedItems = 3
+
+	// maxStringLength is the number of characters that are generated in
+	// addition to the minimum length required by the schema.
+	maxStringLength = 16
+)
+
+// Generate returns a random JSON value for s. The value satisfies the types,
+// enums, formats, lengths and bounds of s, but not necessarily patterns,
+// uniqueness and CEL rules. A random scalar is returned if s is nil.
+func Generate(s *structuralschema.Structural, src *Source) any {
+	return generate(s, src, maxDepth)
+}
+
+func generate(s *structuralschema.Structural, src *Source, depth int) any {
+	if s == nil {
+		return GenerateScalar(src)
+	}
+	if vv := s.ValueValidation; vv != nil && len(vv.Enum) > 0 {
+		return runtime.DeepCopyJSONValue(vv.Enum[src.Intn(len(vv.Enum))].Object)
+	}
+	if s.XIntOrString {
+		if src.Bool() {
+			return generateString(s, src)
+		}
+		return generateInteger(s, src)
+	}
+
+	switch s.Type {
+	case "object":
+		return generateObject(s, src, depth)
+	case "array":
+		return generateArray(s, src, depth)
+	case "string":
+		return generateString(s, src)
+	case "integer":
+		return generateInteger(s, src)
+	case "number":
+		return generateNumber(s, src)
+	case "boolean":
+		return src.Bool()
+	}
+	if s.XPreserveUnknownFields {
+		return map[string]any{}
+	}
+	return GenerateScalar(src)
+}
+
+// GenerateScalar returns a random string, integer, number, bool or nil.
+func GenerateScalar(src *Source) any {
+	switch src.Intn(5) {
+	case 1:
+		return src.Int64()
+	case 2:
+		return src.Float64()
+	case 3:
+		return src.Bool()
+	case 4:
+		return nil
+	}
+	return src.String(0, maxStringLength)
+}
+
+func generateObject(s *structuralschema.Structural, src *Source, depth int) map[string]any {
+	out := map[string]any{}
+	if s.XEmbeddedResource {
+		out["apiVersion"] = "example.org/v1"
+		out["kind"] = "Example"
+		out["metadata"] = map[string]any{"name": "example"}
+	}
+
+	required := map[string]bool{}
+	if s.ValueValidation != nil {
+		for _, r := range s.ValueValidation.Required {
+			required[r] = true
+		}
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !required[name] && (depth <= 0 || !src.Bool()) {
+			continue
+		}
+		prop := s.Properties[name]
+		out[name] = generate(&prop, src, depth-1)
+	}
+
+	if ap := s.AdditionalProperties; ap != nil && (ap.Structural != nil || ap.Bool) && depth > 0 {
+		n := minCount(s.ValueValidation, true) + src.Intn(maxGeneratedItems+1)
+		for range n {
+			key := src.String(1, maxStringLength)
+			if _, exists := out[key]; exists {
+				continue
+			}
+			out[key] = generate(ap.Structural, src, depth-1)
+		}
+	}
+	return out
+}
+
+func generateArray(s *structuralschema.Structural, src *Source, depth int) []any {
+	lo := minCount(s.ValueValidation, false)
+	n := lo
+	if depth > 0 {
+		n += src.Intn(maxGeneratedItems + 1)
+	}
+	if vv := s.ValueValidation; vv != nil && vv.MaxItems != nil {
+		n = min(n, int(*vv.MaxItems))
+	}
+	out := make([]any, n)
+	for i := range out {
+		out[i] = generate(s.Items, src, depth-1)
+	}
+	return out
+}
+
+// minCount returns the minimum number of properties or items of vv.
+func minCount(vv *structuralschema.ValueValidation, properties bool) int {
+	switch {
+	case vv == nil:
+		return 0
+	case properties && vv.MinProperties != nil:
+		return int(*vv.MinProperties)
+	case !properties && vv.MinItems != nil:
+		return int(*vv.MinItems)
+	}
+	return 0
+}
+
+func generateString(s *structuralschema.Structural, src *Source) string {
+	vv := s.ValueValidation
+	if vv == nil {
+		return src.String(0, maxStringLength)
+	}
+	switch vv.Format {
+	case "date-time":
+		return time.Unix(src.Int64Range(0, math.MaxInt32), 0).UTC().Format(time.RFC3339)
+	case "date":
+		return time.Unix(src.Int64Range(0, math.MaxInt32), 0).UTC().Format(time.DateOnly)
+	}
+	lo, hi := 0, maxStringLength
+	if vv.MinLength != nil {
+		lo = int(*vv.MinLength)
+		hi = lo + maxStringLength
+	}
+	if vv.MaxLength != nil {
+		hi = min(hi, int(*vv.MaxLength))
+	}
+	return src.String(lo, max(lo, hi))
+}
+
+func generateInteger(s *structuralschema.Structural, src *Source) int64 {
+	vv := s.ValueValidation
+	if vv == nil || (vv.Minimum == nil && vv.Maximum == nil) {
+		return src.Int64()
+	}
+	lo, hi := int64(math.MinInt32), int64(math.MaxInt32)
+	if vv.Minimum != nil {
+		lo = int64(math.Ceil(*vv.Minimum))
+		if vv.ExclusiveMinimum && float64(lo) == *vv.Minimum {
+			lo++
+		}
+		hi = max(hi, lo)
+	}
+	if vv.Maximum != nil {
+		hi = int64(math.Floor(*vv.Maximum))
+		if vv.ExclusiveMaximum && float64(hi) == *vv.Maximum {
+			hi--
+		}
+		if vv.Minimum == nil {
+			lo = min(lo, hi)
+		}
+	}
+	return src.Int64Range(lo, hi)
+}
+
+func generateNumber(s *structuralschema.Structural, src *Source) float64 {
+	vv := s.ValueValidation
+	if vv == nil || (vv.Minimum == nil && vv.Maximum == nil) {
+		return src.Float64()
+	}
+	// Integral numbers avoid rounding issues at exclusive bounds.
+	return float64(generateInteger(s, src))
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package fuzz
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/schema"
+)
+
+const testCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: buckets.example.org
+spec:
+  group: example.org
+  names:
+    kind: Bucket
+    plural: buckets
+  scope: Cluster
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          required: [spec]
+          properties:
+            spec:
+              type: object
+              required: [region, size]
+              properties:
+                region:
+                  type: string
+                  enum: [eu, us]
+                size:
+                  type: integer
+                  minimum: 1
+                  maximum: 10
+                ratio:
+                  type: number
+                  exclusiveMinimum: true
+                  minimum: 0
+                  maximum: 1
+                name:
+                  type: string
+                  minLength: 3
+                  maxLength: 5
+                created:
+                  type: string
+                  format: date-time
+                zones:
+                  type: array
+                  minItems: 1
+                  maxItems: 2
+                  items:
+                    type: string
+                tags:
+                  type: object
+                  additionalProperties:
+                    type: string
+                port:
+                  x-kubernetes-int-or-string: true
+                template:
+                  type: object
+                  x-kubernetes-embedded-resource: true
+                  x-kubernetes-preserve-unknown-fields: true
+`
+
+var testGVK = runtimeschema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Bucket"}
+
+func testSchema(t *testing.T) (*schema.Validator, *structuralschema.Structural) {
+	t.Helper()
+	v := schema.NewValidator()
+	if err := v.AddYAML([]byte(testCRD)); err != nil {
+		t.Fatal(err)
+	}
+	return v, v.Structural(testGVK)
+}
+
+func TestGenerateIsValid(t *testing.T) {
+	v, s := testSchema(t)
+	for seed := range uint64(200) {
+		obj, ok := Generate(s, NewSource(randomBytes(seed, 4096))).(map[string]any)
+		if !ok {
+			t.Fatalf("seed %d: generated value is not an object", seed)
+		}
+		u := &unstructured.Unstructured{Object: obj}
+		u.SetGroupVersionKind(testGVK)
+		errs, err := v.Validate(u, schema.ValidateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) > 0 {
+			t.Errorf("seed %d: generated object is invalid:\n%s\n%v", seed, schema.FormatErrors(errs, "  "), obj)
+		}
+	}
+}
+
+func TestGenerateMinimal(t *testing.T) {
+	_, s := testSchema(t)
+	// An exhausted source yields the smallest choices, i.e. only required
+	// fields with their minimal values.
+	got := Generate(s, NewSource(nil))
+	want := map[string]any{"spec": map[string]any{"region": "eu", "size": int64(1)}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("-want +got\n%s", diff)
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	_, s := testSchema(t)
+	data := randomBytes(42, 4096)
+	if diff := cmp.Diff(Generate(s, NewSource(data)), Generate(s, NewSource(data))); diff != "" {
+		t.Errorf("the same bytes generated different objects: -first +second\n%s", diff)
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package fuzz
+
+import (
+	"sort"
+
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+)
+
+// maxMutations is the maximum number of mutations applied by [Mutate].
+const maxMutations = 4
+
+// target is a field of an object that can be mutated.
+type target struct {
+	schema *structuralschema.Structural
+	// current is the value of the field or nil if it does not exist yet.
+	current any
+	exists  bool
+	// set is nil if no new value can be generated for the field.
+	set    func(v any)
+	remove func()
+}
+
+// Mutate applies a random number of mutations to obj in place and returns the
+// number of mutations applied. A mutation either removes a field or list item,
+// or sets a field to a new value.
+//
+// If s is not nil, new values are generated from the schema of the field and
+// fields of the schema that do not exist yet may be added. Otherwise, only
+// existing scalar fields are set to new values of the same type.
+//
+// The top-level fields in skip are never mutated.
+func Mutate(obj map[string]any, s *structuralschema.Structural, src *Source, skip ...string) int {
+	skipped := map[string]bool{}
+	for _, k := range skip {
+		skipped[k] = true
+	}
+
+	n := src.Intn(maxMutations + 1)
+	for i := range n {
+		targets := collectObjectTargets(obj, s, skipped)
+		if len(targets) == 0 {
+			return i
+		}
+		t := targets[src.Intn(len(targets))]
+		switch {
+		case t.set == nil || (t.exists && src.Intn(3) == 0):
+			t.remove()
+		case t.schema != nil:
+			t.set(Generate(t.schema, src))
+		default:
+			t.set(generateLike(t.current, src))
+		}
+	}
+	return n
+}
+
+// generateLike returns a random value of the same type as v.
+func generateLike(v any, src *Source) any {
+	switch v.(type) {
+	case string:
+		return src.String(0, maxStringLength)
+	case float64:
+		return src.Float64()
+	case int64:
+		return src.Int64()
+	case bool:
+		return src.Bool()
+	}
+	return GenerateScalar(src)
+}
+
+func collectObjectTargets(obj map[string]any, s *structuralschema.Structural, skip map[string]bool) []target {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		if !skip[k] {
+			keys = append(keys, k)
+		}
+	}
+	if s != nil {
+		for k := range s.Properties {
+			if _, exists := obj[k]; !exists && !skip[k] {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	targets := []target{}
+	for _, k := range keys {
+		ps := propertySchema(s, k)
+		v, exists := obj[k]
+		targets = append(targets, collectTargets(v, exists, ps,
+			func(nv any) { obj[k] = nv },
+			func() { delete(obj, k) },
+		)...)
+	}
+	return targets
+}
+
+func collectListTargets(list []any, s *structuralschema.Structural, setList func(v any)) []target {
+	var items *structuralschema.Structural
+	if s != nil {
+		items = s.Items
+	}
+	targets := []target{}
+	for i, v := range list {
+		targets = append(targets, collectTargets(v, true, items,
+			func(nv any) { list[i] = nv },
+			func() { setList(append(append([]any{}, list[:i]...), list[i+1:]...)) },
+		)...)
+	}
+	return targets
+}
+
+// collectTargets returns the target for the field v itself and all targets
+// nested in it.
+func collectTargets(v any, exists bool, s *structuralschema.Structural, set func(any), remove func()) []target {
+	t := target{schema: s, current: v, exists: exists, set: set, remove: remove}
+	switch vv := v.(type) {
+	case map[string]any:
+		nested := collectObjectTargets(vv, s, nil)
+		if s == nil {
+			// Without a schema, a value of the same type can't be generated
+			// for objects, so they can only be removed.
+			t.set = nil
+		}
+		return append([]target{t}, nested...)
+	case []any:
+		nested := collectListTargets(vv, s, set)
+		if s == nil {
+			t.set = nil
+		}
+		return append([]target{t}, nested...)
+	}
+	if !exists && s == nil {
+		return nil
+	}
+	return []target{t}
+}
+
+// propertySchema returns the schema of the property k of the object schema s
+// or nil if it is unknown.
+func propertySchema(s *structuralschema.Structural, k string) *structuralschema.Structural {
+	if s == nil {
+		return nil
+	}
+	if p, exists := s.Properties[k]; exists {
+		return &p
+	}
+	if s.AdditionalProperties != nil {
+		return s.AdditionalProperties.Structural
+	}
+	return nil
+}
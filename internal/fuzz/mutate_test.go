@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package fuzz
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/schema"
+)
+
+func testObject() map[string]any {
+	return map[string]any{
+		"apiVersion": "example.org/v1",
+		"kind":       "Bucket",
+		"spec": map[string]any{
+			"region": "eu",
+			"size":   int64(2),
+			"zones":  []any{"a", "b"},
+			"tags":   map[string]any{"team": "a"},
+		},
+	}
+}
+
+func TestMutateSkipsFields(t *testing.T) {
+	_, s := testSchema(t)
+	for seed := range uint64(200) {
+		obj := testObject()
+		Mutate(obj, s, NewSource(randomBytes(seed, 4096)), "apiVersion", "kind")
+		if obj["apiVersion"] != "example.org/v1" || obj["kind"] != "Bucket" {
+			t.Fatalf("seed %d: skipped fields were mutated: %v", seed, obj)
+		}
+	}
+}
+
+func TestMutateWithoutSchema(t *testing.T) {
+	for seed := range uint64(200) {
+		obj := testObject()
+		if n := Mutate(obj, nil, NewSource(randomBytes(seed, 4096))); n > maxMutations {
+			t.Fatalf("seed %d: %d mutations exceed the maximum of %d", seed, n, maxMutations)
+		}
+		// Without a schema, fields are only removed or set to values of the
+		// same type and no fields are added.
+		for k, v := range flatten(obj, "") {
+			want, exists := flatten(testObject(), "")[k]
+			if !exists {
+				t.Fatalf("seed %d: field %s was added: %v", seed, k, obj)
+			}
+			if diff := cmp.Diff(typeName(want), typeName(v)); diff != "" {
+				t.Fatalf("seed %d: type of field %s changed: -want +got\n%s", seed, k, diff)
+			}
+		}
+	}
+}
+
+func TestMutateWithSchema(t *testing.T) {
+	v, s := testSchema(t)
+	added := false
+	for seed := range uint64(200) {
+		obj := testObject()
+		Mutate(obj, s, NewSource(randomBytes(seed, 4096)), "apiVersion", "kind")
+		if spec, ok := obj["spec"].(map[string]any); ok && spec["ratio"] != nil {
+			added = true
+		}
+
+		// New values are generated from the schema, only removed fields and
+		// list items may make the object invalid.
+		u := &unstructured.Unstructured{Object: runtime.DeepCopyJSON(obj)}
+		errs, err := v.Validate(u, schema.ValidateOptions{IgnoreRequired: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs = errs.Filter(func(e error) bool {
+			var fe *field.Error
+			return errors.As(e, &fe) && fe.Type == field.ErrorTypeInvalid && strings.Contains(fe.Detail, "should have at least")
+		})
+		if len(errs) > 0 {
+			t.Errorf("seed %d: mutated object is invalid:\n%s\n%v", seed, schema.FormatErrors(errs, "  "), obj)
+		}
+	}
+	if !added {
+		t.Error("no mutation added the field spec.ratio of the schema")
+	}
+}
+
+func TestMutateExhausted(t *testing.T) {
+	obj := testObject()
+	if n := Mutate(obj, nil, NewSource(nil)); n != 0 {
+		t.Errorf("want no mutations from an exhausted source, got %d", n)
+	}
+	if diff := cmp.Diff(testObject(), obj); diff != "" {
+		t.Errorf("-want +got\n%s", diff)
+	}
+}
+
+func flatten(v any, prefix string) map[string]any {
+	out := map[string]any{prefix: v}
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, e := range vv {
+			for p, f := range flatten(e, prefix+"."+k) {
+				out[p] = f
+			}
+		}
+	case []any:
+		for _, e := range vv {
+			for p, f := range flatten(e, prefix+"[]") {
+				out[p] = f
+			}
+		}
+	}
+	return out
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case int64, float64:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "null"
+}
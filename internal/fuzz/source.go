@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fuzz generates and mutates JSON objects, optionally guided by a
+// structural schema.
+//
+// All random choices are drawn from a [Source] of bytes, so the same bytes
+// always produce the same objects. This allows a fuzzing engine to explore
+// objects by mutating bytes and a shrinker to minimize objects by minimizing
+// bytes. An exhausted source returns zero values, which yield the smallest
+// possible choices.
+package fuzz
+
+// alphabet contains the characters of generated strings.
+const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:/ äß✓"
+
+// Source provides random choices based on a stream of bytes.
+type Source struct {
+	data []byte
+	pos  int
+}
+
+// NewSource returns a [Source] that draws its choices from data.
+func NewSource(data []byte) *Source {
+	return &Source{data: data}
+}
+
+// Used returns the number of bytes that have been drawn from s.
+func (s *Source) Used() int {
+	return min(s.pos, len(s.data))
+}
+
+// Byte returns the next byte or 0 if s is exhausted.
+func (s *Source) Byte() byte {
+	if s.pos >= len(s.data) {
+		s.pos++
+		return 0
+	}
+	b := s.data[s.pos]
+	s.pos++
+	return b
+}
+
+// Intn returns an int in [0, n). It returns 0 without drawing any bytes if n
+// is less than 2.
+func (s *Source) Intn(n int) int {
+	if n < 2 {
+		return 0
+	}
+	var v uint64
+	for m := uint64(n - 1); m > 0; m >>= 8 {
+		v = v<<8 | uint64(s.Byte())
+	}
+	return int(v % uint64(n))
+}
+
+// Bool returns a random bool.
+func (s *Source) Bool() bool {
+	return s.Byte()&1 == 1
+}
+
+// Int64 returns a random int64 in the range of an int32, which keeps values
+// readable.
+func (s *Source) Int64() int64 {
+	var v uint32
+	for range 4 {
+		v = v<<8 | uint32(s.Byte())
+	}
+	return int64(int32(v))
+}
+
+// Int64Range returns a random int64 in [lo, hi].
+func (s *Source) Int64Range(lo, hi int64) int64 {
+	if hi <= lo {
+		return lo
+	}
+	span := uint64(hi - lo)
+	var v uint64
+	for m := span; m > 0; m >>= 8 {
+		v = v<<8 | uint64(s.Byte())
+	}
+	if span == ^uint64(0) {
+		return lo + int64(v)
+	}
+	return lo + int64(v%(span+1))
+}
+
+// Float64 returns a random float64 that may have a fractional part.
+func (s *Source) Float64() float64 {
+	return float64(s.Int64()) / float64(int64(1)<<s.Intn(8))
+}
+
+// String returns a random string whose length in characters is in
+// [minLen, maxLen].
+func (s *Source) String(minLen, maxLen int) string {
+	chars := []rune(alphabet)
+	n := minLen + s.Intn(maxLen-minLen+1)
+	out := make([]rune, n)
+	for i := range out {
+		out[i] = chars[s.Intn(len(chars))]
+	}
+	return string(out)
+}
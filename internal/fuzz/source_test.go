@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package fuzz
+
+import (
+	"math/rand/v2"
+	"testing"
+	"unicode/utf8"
+)
+
+func randomBytes(seed uint64, n int) []byte {
+	rnd := rand.New(rand.NewPCG(seed, seed)) //nolint:gosec // No need for cryptographic randomness.
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(rnd.Uint32())
+	}
+	return data
+}
+
+func TestSourceExhausted(t *testing.T) {
+	src := NewSource([]byte{7})
+	if got := src.Byte(); got != 7 {
+		t.Errorf("want first byte 7, got %d", got)
+	}
+	if got := src.Intn(10); got != 0 {
+		t.Errorf("want 0 from an exhausted source, got %d", got)
+	}
+	if got := src.String(2, 5); got != "aa" {
+		t.Errorf("want the minimal string %q from an exhausted source, got %q", "aa", got)
+	}
+	if got := src.Used(); got != 1 {
+		t.Errorf("want 1 used byte, got %d", got)
+	}
+}
+
+func TestSourceRanges(t *testing.T) {
+	src := NewSource(randomBytes(1, 1<<16))
+	for range 1000 {
+		if v := src.Intn(300); v < 0 || v >= 300 {
+			t.Fatalf("Intn(300) returned %d", v)
+		}
+		if v := src.Int64Range(-5, 5); v < -5 || v > 5 {
+			t.Fatalf("Int64Range(-5, 5) returned %d", v)
+		}
+		if s := src.String(1, 3); utf8.RuneCountInString(s) < 1 || utf8.RuneCountInString(s) > 3 {
+			t.Fatalf("String(1, 3) returned %q", s)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package schema validates objects against the OpenAPI schemas of
+// CustomResourceDefinitions and CompositeResourceDefinitions the same way the
+// API server does.
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/defaulting"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+const (
+	kindCRD = "CustomResourceDefinition"
+	kindXRD = "CompositeResourceDefinition"
+)
+
+// Validator validates objects against the schemas of all versions of the
+// definitions added to it.
+type Validator struct {
+	versions map[runtimeschema.GroupVersionKind]*version
+}
+
+type version struct {
+	served     bool
+	validator  validation.SchemaValidator
+	structural *structuralschema.Structural
+	cel        *cel.Validator
+}
+
+// ValidateOptions configure how an object is validated.
+type ValidateOptions struct {
+	// IgnoreRequired ignores errors about missing required fields. This is
+	// useful for partial objects, like the desired composite of a function.
+	IgnoreRequired bool
+}
+
+// NewValidator returns an empty [Validator].
+func NewValidator() *Validator {
+	return &Validator{versions: map[runtimeschema.GroupVersionKind]*version{}}
+}
+
+// Merge adds all schemas of other to v. Schemas of v are overwritten if other
+// contains the same GroupVersionKind.
+func (v *Validator) Merge(other *Validator) {
+	if other == nil {
+		return
+	}
+	for gvk, ver := range other.versions {
+		v.versions[gvk] = ver
+	}
+}
+
+// AddYAML adds all CustomResourceDefinitions and CompositeResourceDefinitions
+// from the given multi-document YAML. Documents of other kinds are ignored.
+func (v *Validator) AddYAML(rawYAML []byte) error {
+	objects, err := yaml.UnmarshalObjects[*unstructured.Unstructured](rawYAML)
+	if err != nil {
+		return errors.Wrap(err, "cannot unmarshal definitions")
+	}
+	for _, o := range objects {
+		if err := v.AddObject(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddObject adds the definition u if it is a CustomResourceDefinition or a
+// CompositeResourceDefinition. Objects of other kinds are ignored.
+func (v *Validator) AddObject(u *unstructured.Unstructured) error {
+	switch u.GetKind() {
+	case kindCRD:
+		crd := &extv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), crd); err != nil {
+			return errors.Wrapf(err, "cannot convert CustomResourceDefinition %s", u.GetName())
+		}
+		return errors.Wrapf(v.AddCRD(crd), "CustomResourceDefinition %s", u.GetName())
+	case kindXRD:
+		crd, err := CRDFromXRD(u)
+		if err != nil {
+			return errors.Wrapf(err, "cannot convert CompositeResourceDefinition %s", u.GetName())
+		}
+		return errors.Wrapf(v.AddCRD(crd), "CompositeResourceDefinition %s", u.GetName())
+	}
+	return nil
+}
+
+// AddCRD adds the schemas of all versions of crd.
+func (v *Validator) AddCRD(crd *extv1.CustomResourceDefinition) error {
+	internal := &apiextensions.CustomResourceDefinition{}
+	if err := extv1.Convert_v1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(crd, internal, nil); err != nil {
+		return errors.Wrap(err, "cannot convert to internal version")
+	}
+	for _, ver := range internal.Spec.Versions {
+		var s *apiextensions.JSONSchemaProps
+		switch {
+		case internal.Spec.Validation != nil:
+			s = internal.Spec.Validation.OpenAPIV3Schema
+		case ver.Schema != nil:
+			s = ver.Schema.OpenAPIV3Schema
+		}
+		if s == nil {
+			continue
+		}
+		sv, _, err := validation.NewSchemaValidator(s)
+		if err != nil {
+			return errors.Wrapf(err, "cannot create schema validator for version %s", ver.Name)
+		}
+		structural, err := structuralschema.NewStructural(s)
+		if err != nil {
+			return errors.Wrapf(err, "cannot create structural schema for version %s", ver.Name)
+		}
+		gvk := runtimeschema.GroupVersionKind{
+			Group:   internal.Spec.Group,
+			Version: ver.Name,
+			Kind:    internal.Spec.Names.Kind,
+		}
+		v.versions[gvk] = &version{
+			served:     ver.Served,
+			validator:  sv,
+			structural: structural,
+			cel:        cel.NewValidator(structural, true, celconfig.PerCallLimit),
+		}
+	}
+	return nil
+}
+
+// Has returns whether v has a schema for gvk.
+func (v *Validator) Has(gvk runtimeschema.GroupVersionKind) bool {
+	_, exists := v.versions[gvk]
+	return exists
+}
+
+// Served returns whether the version gvk is served by its definition.
+func (v *Validator) Served(gvk runtimeschema.GroupVersionKind) bool {
+	ver, exists := v.versions[gvk]
+	return exists && ver.served
+}
+
+// ServedKinds returns the GroupVersionKinds of all served versions sorted by their
+// string representation.
+func (v *Validator) ServedKinds() []runtimeschema.GroupVersionKind {
+	out := []runtimeschema.GroupVersionKind{}
+	for gvk, ver := range v.versions {
+		if ver.served {
+			out = append(out, gvk)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out
+}
+
+// Structural returns the structural schema of gvk or nil if v has no schema
+// for gvk.
+func (v *Validator) Structural(gvk runtimeschema.GroupVersionKind) *structuralschema.Structural {
+	ver, exists := v.versions[gvk]
+	if !exists {
+		return nil
+	}
+	return ver.structural
+}
+
+// Default applies the defaults of the schema of obj's GroupVersionKind to obj
+// in place. It is a noop if v has no schema for obj.
+func (v *Validator) Default(obj *unstructured.Unstructured) {
+	ver, exists := v.versions[obj.GroupVersionKind()]
+	if !exists {
+		return
+	}
+	defaulting.Default(obj.UnstructuredContent(), ver.structural)
+}
+
+// Validate validates obj against the schema of its GroupVersionKind the same
+// way the API server would on creation: it applies schema defaults, reports
+// fields that would be pruned as unknown, validates the OpenAPI schema and
+// evaluates the x-kubernetes-validations CEL rules. obj is not modified.
+//
+// It returns an error if v has no schema for obj.
+func (v *Validator) Validate(obj *unstructured.Unstructured, opts ValidateOptions) (field.ErrorList, error) {
+	gvk := obj.GroupVersionKind()
+	ver, exists := v.versions[gvk]
+	if !exists {
+		return nil, errors.Errorf("no schema for %s", gvk.String())
+	}
+
+	obj, err := normalizeNumbers(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := field.ErrorList{}
+	pruned := obj.DeepCopy().UnstructuredContent()
+	for _, p := range pruning.PruneWithOptions(pruned, ver.structural, true, structuralschema.UnknownFieldPathOptions{TrackUnknownFieldPaths: true}) {
+		errs = append(errs, field.Forbidden(field.NewPath(p), "unknown field"))
+	}
+
+	defaulted := obj.DeepCopy()
+	defaulting.Default(defaulted.UnstructuredContent(), ver.structural)
+
+	errs = append(errs, validation.ValidateCustomResource(nil, defaulted.UnstructuredContent(), ver.validator)...)
+	if ver.cel != nil {
+		celErrs, _ := ver.cel.Validate(context.Background(), nil, ver.structural, defaulted.UnstructuredContent(), nil, celconfig.RuntimeCELCostBudget)
+		errs = append(errs, celErrs...)
+	}
+
+	if opts.IgnoreRequired {
+		errs = errs.Filter(func(e error) bool {
+			var fe *field.Error
+			return errors.As(e, &fe) && fe.Type == field.ErrorTypeRequired
+		})
+	}
+	return errs, nil
+}
+
+// normalizeNumbers returns a copy of obj whose integral numbers are int64
+// instead of float64, like the API server decodes them. Objects converted from
+// protobuf structs only contain float64 numbers.
+func normalizeNumbers(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	raw, err := json.Marshal(obj.UnstructuredContent())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal object")
+	}
+	out := map[string]interface{}{}
+	if err := utiljson.Unmarshal(raw, &out); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal object")
+	}
+	return &unstructured.Unstructured{Object: out}, nil
+}
+
+// FormatErrors formats errs as one error per line with the given indent.
+func FormatErrors(errs field.ErrorList, indent string) string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = fmt.Sprintf("%s%s", indent, e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
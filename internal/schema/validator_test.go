@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package schema
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+const testCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: buckets.example.org
+spec:
+  group: example.org
+  names:
+    kind: Bucket
+    plural: buckets
+  scope: Cluster
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [region]
+              x-kubernetes-validations:
+                - rule: "self.size <= 10"
+                  message: size must not exceed 10
+              properties:
+                region:
+                  type: string
+                  enum: [eu, us]
+                size:
+                  type: integer
+                  default: 1
+    - name: v1alpha1
+      served: false
+      storage: false
+      schema:
+        openAPIV3Schema:
+          type: object
+`
+
+const testXRD = `
+apiVersion: apiextensions.crossplane.io/v1
+kind: CompositeResourceDefinition
+metadata:
+  name: xbuckets.example.org
+spec:
+  group: example.org
+  names:
+    kind: XBucket
+    plural: xbuckets
+  versions:
+    - name: v1
+      served: true
+      referenceable: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                region:
+                  type: string
+`
+
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+	v := NewValidator()
+	if err := v.AddYAML([]byte(testCRD + "---" + testXRD)); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func mustObject(t *testing.T, rawYAML string) *unstructured.Unstructured {
+	t.Helper()
+	u := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(rawYAML), &u.Object); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestValidate(t *testing.T) {
+	v := newTestValidator(t)
+
+	cases := map[string]struct {
+		object string
+		opts   ValidateOptions
+		want   []string
+	}{
+		"Valid": {
+			object: `{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu, size: 2}}`,
+		},
+		"IntegralFloat": {
+			// Objects converted from protobuf structs contain float64 numbers.
+			object: `{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu, size: 2.0}}`,
+		},
+		"UnknownField": {
+			object: `{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu, zone: a}}`,
+			want:   []string{"spec.zone: Forbidden: unknown field"},
+		},
+		"Enum": {
+			object: `{apiVersion: example.org/v1, kind: Bucket, spec: {region: ap}}`,
+			want:   []string{`spec.region: Unsupported value: "ap": supported values: "eu", "us"`},
+		},
+		"CELRule": {
+			object: `{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu, size: 11}}`,
+			want:   []string{"spec: Invalid value: \"object\": size must not exceed 10"},
+		},
+		"Required": {
+			object: `{apiVersion: example.org/v1, kind: Bucket, spec: {}}`,
+			want:   []string{"spec.region: Required value"},
+		},
+		"IgnoreRequired": {
+			object: `{apiVersion: example.org/v1, kind: Bucket, spec: {}}`,
+			opts:   ValidateOptions{IgnoreRequired: true},
+		},
+		"CompositeWithoutSpec": {
+			object: `{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}}`,
+			want:   []string{"spec: Required value"},
+		},
+		"CompositeManagedFields": {
+			object: `{apiVersion: example.org/v1, kind: XBucket, spec: {region: eu, compositionRef: {name: c}, resourceRefs: [{kind: Bucket}]}, status: {conditions: [{type: Ready}]}}`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			u := mustObject(t, c.object)
+			before := u.DeepCopy()
+			errs, err := v.Validate(u, c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := errorStrings(errs); !slices.Equal(got, c.want) {
+				t.Errorf("want errors %q, got %q", c.want, got)
+			}
+			if diff := cmp.Diff(before, u); diff != "" {
+				t.Errorf("Validate modified the object: -before +after\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateUnknownKind(t *testing.T) {
+	v := newTestValidator(t)
+	if _, err := v.Validate(mustObject(t, `{apiVersion: example.org/v2, kind: Bucket}`), ValidateOptions{}); err == nil {
+		t.Error("expected an error for a kind without schema")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	v := newTestValidator(t)
+	u := mustObject(t, `{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu}}`)
+	v.Default(u)
+	if got, _, _ := unstructured.NestedInt64(u.Object, "spec", "size"); got != 1 {
+		t.Errorf("want default size 1, got %d", got)
+	}
+}
+
+func TestServedKinds(t *testing.T) {
+	v := newTestValidator(t)
+	var got []string
+	for _, gvk := range v.ServedKinds() {
+		got = append(got, gvk.String())
+	}
+	want := []string{"example.org/v1, Kind=Bucket", "example.org/v1, Kind=XBucket"}
+	if !slices.Equal(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func errorStrings(errs field.ErrorList) []string {
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.Error())
+	}
+	return out
+}
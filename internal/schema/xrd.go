@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package schema
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/pkg/errors"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// xrd is the subset of a Crossplane CompositeResourceDefinition that is
+// required to derive the CRD of its composite resource.
+type xrd struct {
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec struct {
+		Group    string                              `json:"group"`
+		Names    extv1.CustomResourceDefinitionNames `json:"names"`
+		Versions []struct {
+			Name          string `json:"name"`
+			Served        bool   `json:"served"`
+			Referenceable bool   `json:"referenceable"`
+			Schema        *struct {
+				OpenAPIV3Schema runtime.RawExtension `json:"openAPIV3Schema"`
+			} `json:"schema,omitempty"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// CRDFromXRD derives the CustomResourceDefinition of the composite resource
+// defined by the CompositeResourceDefinition u.
+//
+// Like Crossplane, it extends the schema of each version with the spec and
+// status fields that Crossplane manages for every composite resource and
+// requires the spec. The managed fields are not validated in detail.
+func CRDFromXRD(u *unstructured.Unstructured) (*extv1.CustomResourceDefinition, error) {
+	in := &xrd{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), in); err != nil {
+		return nil, errors.Wrap(err, "cannot convert CompositeResourceDefinition")
+	}
+
+	crd := &extv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: in.GetName()},
+		Spec: extv1.CustomResourceDefinitionSpec{
+			Group: in.Spec.Group,
+			Names: in.Spec.Names,
+			Scope: extv1.ClusterScoped,
+		},
+	}
+	for _, v := range in.Spec.Versions {
+		props := extv1.JSONSchemaProps{}
+		if v.Schema != nil && len(v.Schema.OpenAPIV3Schema.Raw) > 0 {
+			if err := json.Unmarshal(v.Schema.OpenAPIV3Schema.Raw, &props); err != nil {
+				return nil, errors.Wrapf(err, "cannot unmarshal schema of version %s", v.Name)
+			}
+		}
+		crd.Spec.Versions = append(crd.Spec.Versions, extv1.CustomResourceDefinitionVersion{
+			Name:    v.Name,
+			Served:  v.Served,
+			Storage: v.Referenceable,
+			Schema: &extv1.CustomResourceValidation{
+				OpenAPIV3Schema: compositeSchema(props),
+			},
+		})
+	}
+	return crd, nil
+}
+
+// compositeSchema extends the schema of an XRD version with the fields that
+// Crossplane adds to every composite resource.
+func compositeSchema(s extv1.JSONSchemaProps) *extv1.JSONSchemaProps {
+	s.Type = "object"
+	if !slices.Contains(s.Required, "spec") {
+		s.Required = append(s.Required, "spec")
+	}
+	if s.Properties == nil {
+		s.Properties = map[string]extv1.JSONSchemaProps{}
+	}
+	s.Properties["apiVersion"] = extv1.JSONSchemaProps{Type: "string"}
+	s.Properties["kind"] = extv1.JSONSchemaProps{Type: "string"}
+	s.Properties["metadata"] = extv1.JSONSchemaProps{Type: "object"}
+
+	spec := s.Properties["spec"]
+	spec.Type = "object"
+	if spec.Properties == nil {
+		spec.Properties = map[string]extv1.JSONSchemaProps{}
+	}
+	for _, name := range []string{
+		"claimRef",
+		"compositionRef",
+		"compositionRevisionRef",
+		"compositionRevisionSelector",
+		"compositionSelector",
+		"publishConnectionDetailsTo",
+		"writeConnectionSecretToRef",
+	} {
+		spec.Properties[name] = preserveUnknownObject()
+	}
+	spec.Properties["compositionUpdatePolicy"] = extv1.JSONSchemaProps{Type: "string"}
+	spec.Properties["environmentConfigRefs"] = preserveUnknownList()
+	spec.Properties["resourceRefs"] = preserveUnknownList()
+	s.Properties["spec"] = spec
+
+	status := s.Properties["status"]
+	status.Type = "object"
+	if status.Properties == nil {
+		status.Properties = map[string]extv1.JSONSchemaProps{}
+	}
+	status.Properties["conditions"] = preserveUnknownList()
+	status.Properties["connectionDetails"] = preserveUnknownObject()
+	status.Properties["claimConditionTypes"] = extv1.JSONSchemaProps{
+		Type:  "array",
+		Items: &extv1.JSONSchemaPropsOrArray{Schema: &extv1.JSONSchemaProps{Type: "string"}},
+	}
+	s.Properties["status"] = status
+
+	return &s
+}
+
+func preserveUnknownObject() extv1.JSONSchemaProps {
+	preserve := true
+	return extv1.JSONSchemaProps{Type: "object", XPreserveUnknownFields: &preserve}
+}
+
+func preserveUnknownList() extv1.JSONSchemaProps {
+	o := preserveUnknownObject()
+	return extv1.JSONSchemaProps{
+		Type:  "array",
+		Items: &extv1.JSONSchemaPropsOrArray{Schema: &o},
+	}
+}
@@ -0,0 +1,213 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package diff computes and formats line based diffs.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// Op is the operation of a line in an edit script.
+type Op int
+
+const (
+	// Equal lines are part of both texts.
+	Equal Op = iota
+	// Delete lines are only part of the first text.
+	Delete
+	// Insert lines are only part of the second text.
+	Insert
+)
+
+// Line is a line of an edit script.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the edit script that transforms a into b. It is based on the
+// longest common subsequence of both, so deleted lines precede inserted ones.
+func Lines(a, b []string) []Line {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	out := make([]Line, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		out = append(out, Line{Op: Equal, Text: l})
+	}
+	out = append(out, lcsLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, Line{Op: Equal, Text: l})
+	}
+	return out
+}
+
+func lcsLines(a, b []string) []Line {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	out := make([]Line, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{Op: Equal, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Op: Delete, Text: a[i]})
+			i++
+		default:
+			out = append(out, Line{Op: Insert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, Line{Op: Delete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, Line{Op: Insert, Text: b[j]})
+	}
+	return out
+}
+
+// Unified formats the edit script as unified diff with the given number of
+// context lines around changes. It returns an empty string if the script
+// contains no changes.
+func Unified(lines []Line, context int) string {
+	b := &strings.Builder{}
+	for start := 0; start < len(lines); {
+		// Find the next change and the end of its hunk, which includes all
+		// changes that are at most 2*context lines apart.
+		first := start
+		for first < len(lines) && lines[first].Op == Equal {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for i := first; i < len(lines) && i <= last+2*context+1; i++ {
+			if lines[i].Op != Equal {
+				last = i
+			}
+		}
+		from := max(first-context, start)
+		to := min(last+context+1, len(lines))
+		writeHunk(b, lines, from, to)
+		start = to
+	}
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, lines []Line, from, to int) {
+	aStart, bStart := 1, 1
+	for _, l := range lines[:from] {
+		if l.Op != Insert {
+			aStart++
+		}
+		if l.Op != Delete {
+			bStart++
+		}
+	}
+	aLen, bLen := 0, 0
+	for _, l := range lines[from:to] {
+		if l.Op != Insert {
+			aLen++
+		}
+		if l.Op != Delete {
+			bLen++
+		}
+	}
+	// An empty range starts at the line before it.
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, l := range lines[from:to] {
+		switch l.Op {
+		case Delete:
+			b.WriteString("-" + l.Text + "\n")
+		case Insert:
+			b.WriteString("+" + l.Text + "\n")
+		default:
+			b.WriteString(" " + l.Text + "\n")
+		}
+	}
+}
+
+// Colorize colors the hunks of unified diffs in s with ANSI escape codes.
+// Text outside of hunks is returned unchanged.
+func Colorize(s string) string {
+	lines := strings.Split(s, "\n")
+	inHunk := false
+	for i, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "@@ -") && strings.HasSuffix(l, " @@"):
+			inHunk = true
+			lines[i] = colorCyan + l + colorReset
+		case !inHunk:
+		case strings.HasPrefix(l, "-"):
+			lines[i] = colorRed + l + colorReset
+		case strings.HasPrefix(l, "+"):
+			lines[i] = colorGreen + l + colorReset
+		case !strings.HasPrefix(l, " "):
+			inHunk = false
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// UseColor returns whether diffs written to w are colored. The mode is either
+// "always", "never" or "auto", which colors diffs if w is a terminal and the
+// environment variable NO_COLOR is not set.
+func UseColor(w io.Writer, mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLines(t *testing.T) {
+	cases := map[string]struct {
+		a, b []string
+		want []Line
+	}{
+		"Equal": {
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b"},
+			want: []Line{{Equal, "a"}, {Equal, "b"}},
+		},
+		"Empty": {
+			want: []Line{},
+		},
+		"OnlyInserted": {
+			b:    []string{"a"},
+			want: []Line{{Insert, "a"}},
+		},
+		"OnlyDeleted": {
+			a:    []string{"a"},
+			want: []Line{{Delete, "a"}},
+		},
+		"Changed": {
+			a:    []string{"a", "b", "c"},
+			b:    []string{"a", "x", "c"},
+			want: []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}},
+		},
+		"LongestCommonSubsequence": {
+			a:    []string{"a", "b", "c", "d", "e"},
+			b:    []string{"b", "x", "d", "e", "f"},
+			want: []Line{{Delete, "a"}, {Equal, "b"}, {Delete, "c"}, {Insert, "x"}, {Equal, "d"}, {Equal, "e"}, {Insert, "f"}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(c.want, Lines(c.a, c.b)); diff != "" {
+				t.Errorf("Lines(...): -want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	cases := map[string]struct {
+		b       []string
+		context int
+		want    string
+	}{
+		"NoChanges": {
+			b:       a,
+			context: 3,
+		},
+		"OneHunk": {
+			b:       []string{"1", "2", "3", "4", "x", "6", "7", "8", "9", "10"},
+			context: 1,
+			want:    "@@ -4,3 +4,3 @@\n 4\n-5\n+x\n 6\n",
+		},
+		"MergedHunks": {
+			b:       []string{"x", "2", "3", "y", "5", "6", "7", "8", "9", "10"},
+			context: 1,
+			want:    "@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n-4\n+y\n 5\n",
+		},
+		"HunksApartByMoreThanTwiceTheContext": {
+			b:       []string{"x", "2", "3", "4", "y", "6", "7", "8", "9", "10"},
+			context: 1,
+			want:    "@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -4,3 +4,3 @@\n 4\n-5\n+y\n 6\n",
+		},
+		"SeparateHunks": {
+			b:       []string{"x", "2", "3", "4", "5", "6", "7", "8", "9", "y"},
+			context: 1,
+			want:    "@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -9,2 +9,2 @@\n 9\n-10\n+y\n",
+		},
+		"AllDeleted": {
+			b:       []string{},
+			context: 1,
+			want:    "@@ -1,10 +0,0 @@\n-1\n-2\n-3\n-4\n-5\n-6\n-7\n-8\n-9\n-10\n",
+		},
+		"Insert": {
+			b:       []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"},
+			context: 1,
+			want:    "@@ -10,1 +10,2 @@\n 10\n+11\n",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(c.want, Unified(Lines(a, c.b), c.context)); diff != "" {
+				t.Errorf("Unified(...): -want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestColorize(t *testing.T) {
+	in := "changed fields: spec\n- not a hunk\n@@ -1,2 +1,2 @@\n a\n-b\n+c\nafter"
+	want := "changed fields: spec\n- not a hunk\n" +
+		colorCyan + "@@ -1,2 +1,2 @@" + colorReset + "\n a\n" +
+		colorRed + "-b" + colorReset + "\n" +
+		colorGreen + "+c" + colorReset + "\nafter"
+	if diff := cmp.Diff(want, Colorize(in)); diff != "" {
+		t.Errorf("Colorize(...): -want +got\n%s", diff)
+	}
+	if got := Colorize("-want +got"); strings.Contains(got, "\x1b") {
+		t.Errorf("Colorize(...): colored text outside of a hunk: %q", got)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	cases := map[string]struct {
+		mode    string
+		noColor bool
+		want    bool
+	}{
+		"Always":          {mode: "always", noColor: true, want: true},
+		"Never":           {mode: "never"},
+		"AutoNoTerminal":  {mode: "auto"},
+		"NoColorDisables": {noColor: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if c.noColor {
+				t.Setenv("NO_COLOR", "1")
+			}
+			if got := UseColor(&bytes.Buffer{}, c.mode); got != c.want {
+				t.Errorf("UseColor(...): want %t, got %t", c.want, got)
+			}
+		})
+	}
+}
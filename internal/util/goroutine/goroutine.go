@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package goroutine inspects the goroutines of the running program.
+package goroutine
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pollInterval is the interval in which goroutines are checked while waiting
+// for them to exit.
+const pollInterval = 10 * time.Millisecond
+
+// Snapshot maps the IDs of all goroutines to their stack traces.
+type Snapshot map[int]string
+
+// Take returns a snapshot of all goroutines that are currently running.
+func Take() Snapshot {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	s := Snapshot{}
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		id, ok := parseID(string(stack))
+		if !ok {
+			continue
+		}
+		s[id] = strings.TrimSpace(string(stack))
+	}
+	return s
+}
+
+// parseID parses the ID from a stack trace that starts with a header like
+// "goroutine 42 [running]:".
+func parseID(stack string) (int, bool) {
+	rest, ok := strings.CutPrefix(stack, "goroutine ")
+	if !ok {
+		return 0, false
+	}
+	idStr, _, ok := strings.Cut(rest, " ")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// Filter returns true for stack traces that should be ignored.
+type Filter func(stack string) bool
+
+// IgnoreTests ignores the goroutines of tests and subtests, which are started
+// concurrently by parallel tests.
+func IgnoreTests() Filter {
+	return func(stack string) bool {
+		return strings.Contains(stack, "\ncreated by testing.(*T).Run")
+	}
+}
+
+// Since returns the stack traces of all goroutines that are running now but
+// were not part of before, ordered by their ID. Goroutines matched by any of
+// the filters are ignored.
+func Since(before Snapshot, filters ...Filter) []string {
+	after := Take()
+	ids := []int{}
+	for id, stack := range after {
+		if _, exists := before[id]; exists || ignored(stack, filters) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	stacks := make([]string, len(ids))
+	for i, id := range ids {
+		stacks[i] = after[id]
+	}
+	return stacks
+}
+
+func ignored(stack string, filters []Filter) bool {
+	for _, f := range filters {
+		if f(stack) {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitSince waits up to grace for all goroutines that were started since
+// before to exit. It returns the stack traces of all goroutines that are
+// still running afterwards.
+func WaitSince(before Snapshot, grace time.Duration, filters ...Filter) []string {
+	deadline := time.Now().Add(grace)
+	for {
+		stacks := Since(before, filters...)
+		if len(stacks) == 0 || !time.Now().Before(deadline) {
+			return stacks
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -5,13 +5,14 @@ package maps
 
 // Merge recursivley merges b into a and returns the result as a new map.
 // If the same entries in a and b contain maps, they are merged recursively.
-// Other values are only shallowly copied into the result map.
+// All other values are deep copied into the result map, so the result never
+// shares nested maps or slices with a or b.
 //
 // Credits go to https://stackoverflow.com/a/70291996
 func Merge[K comparable](a, b map[K]interface{}) map[K]interface{} {
 	out := make(map[K]interface{}, len(a))
 	for k, v := range a {
-		out[k] = v
+		out[k] = deepCopyValue[K](v)
 	}
 	for k, v := range b {
 		if v, ok := v.(map[K]interface{}); ok {
@@ -22,7 +23,27 @@ func Merge[K comparable](a, b map[K]interface{}) map[K]interface{} {
 				}
 			}
 		}
-		out[k] = v
+		out[k] = deepCopyValue[K](v)
 	}
 	return out
 }
+
+// deepCopyValue returns a deep copy of v if it is a map or slice. Other values
+// are returned as they are.
+func deepCopyValue[K comparable](v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[K]interface{}:
+		out := make(map[K]interface{}, len(v))
+		for k, vv := range v {
+			out[k] = deepCopyValue[K](vv)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, vv := range v {
+			out[i] = deepCopyValue[K](vv)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package maps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMerge(t *testing.T) {
+	a := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"region": "eu",
+			"tags":   []interface{}{map[string]interface{}{"key": "team"}},
+		},
+		"status": map[string]interface{}{"ready": true},
+	}
+	b := map[string]interface{}{
+		"spec":   map[string]interface{}{"size": 1.0},
+		"status": "replaced",
+		"list":   []interface{}{"b"},
+	}
+	want := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"region": "eu",
+			"size":   1.0,
+			"tags":   []interface{}{map[string]interface{}{"key": "team"}},
+		},
+		"status": "replaced",
+		"list":   []interface{}{"b"},
+	}
+
+	got := Merge(a, b)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-want +got\n%s", diff)
+	}
+
+	// The result must not share nested maps or slices with a or b.
+	got["spec"].(map[string]interface{})["region"] = "us"
+	got["spec"].(map[string]interface{})["tags"].([]interface{})[0].(map[string]interface{})["key"] = "env"
+	got["list"].([]interface{})[0] = "changed"
+	if v := a["spec"].(map[string]interface{})["region"]; v != "eu" {
+		t.Errorf("a was modified through the result: spec.region is %v", v)
+	}
+	if v := a["spec"].(map[string]interface{})["tags"].([]interface{})[0].(map[string]interface{})["key"]; v != "team" {
+		t.Errorf("a was modified through the result: spec.tags[0].key is %v", v)
+	}
+	if v := b["list"].([]interface{})[0]; v != "b" {
+		t.Errorf("b was modified through the result: list[0] is %v", v)
+	}
+}
@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package yaml
+
+import (
+	"bytes"
+
+	"sigs.k8s.io/yaml"
+)
+
+// documentSeparator separates the documents of a multi-document YAML stream.
+const documentSeparator = "---\n"
+
+// Marshal v into a YAML document.
+func Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// MarshalObjects marshals all objects into a multi-document YAML stream.
+func MarshalObjects[T any](objects []T) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for i, o := range objects {
+		data, err := yaml.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString(documentSeparator)
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
@@ -18,6 +18,12 @@ func Unmarshal(data []byte, v interface{}) error {
 	return yaml.Unmarshal(data, v)
 }
 
+// UnmarshalStrict the given data into v. Unlike [Unmarshal], it fails on
+// fields that v does not define and on duplicate fields.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	return yaml.UnmarshalStrict(data, v)
+}
+
 // UnmarshalObjects parses all objects from a multi-document YAML stream.
 // Documents that are empty (including no comments) or contain only white space
 // are ignored.
@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+)
+
+// EnvMutation is the environment variable that enables mutation testing for
+// all tests if set to true. See [WithMutationTesting].
+const EnvMutation = "FNTEST_MUTATION"
+
+// WithMutationTesting checks whether the expectations of a passing test would
+// detect changes of the response. It mutates the actual response of the
+// function once per mutant:
+//
+//   - each desired composed resource and result is removed,
+//   - each field of the desired composite, the desired composed resources and
+//     the context is removed and
+//   - each of these fields is changed to a different value.
+//
+// Every mutant is evaluated against all expectations on the response and the
+// registered policies. Mutants that pass are reported as warning, because the
+// expectations or ignore rules of the test are too loose to detect them.
+// Expectations that rerun the function, like [ExpectDeterministic], are not
+// evaluated.
+func WithMutationTesting() TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.mutationTesting = true
+	}
+}
+
+// mutant is a modification of the response of a function.
+type mutant struct {
+	description string
+	mutate      func(res *fnapi.RunFunctionResponse) error
+}
+
+// evaluateMutants returns an assertion that lists all mutants of res that
+// the expectations of the test do not detect. It returns nil if mutation
+// testing is not enabled.
+func (tc *FunctionTest) evaluateMutants(res *fnapi.RunFunctionResponse) []Assertion {
+	if !tc.mutationTesting {
+		if enabled, _ := strconv.ParseBool(os.Getenv(EnvMutation)); !enabled {
+			return nil
+		}
+	}
+
+	mutants := responseMutants(res)
+	var survived []string
+	for _, m := range mutants {
+		mut := proto.Clone(res).(*fnapi.RunFunctionResponse) //nolint:forcetypeassert // Clone returns the same type.
+		if err := m.mutate(mut); err != nil {
+			survived = append(survived, fmt.Sprintf("%s (cannot mutate: %s)", m.description, err))
+			continue
+		}
+		if !tc.detectsMutant(mut) {
+			survived = append(survived, m.description)
+		}
+	}
+
+	a := passedAssertion("Mutants")
+	if len(survived) > 0 {
+		a.Status = AssertionWarning
+		a.Message = fmt.Sprintf("%d of %d mutants of the response survived", len(survived), len(mutants))
+		a.Diff = "  " + strings.Join(survived, "\n  ")
+	}
+	return []Assertion{a}
+}
+
+// detectsMutant returns true if any expectation on the response or any policy
+// fails for the mutated response.
+func (tc *FunctionTest) detectsMutant(res *fnapi.RunFunctionResponse) bool {
+	assertions := tc.compareResponseToExpectedResources(res, nil)
+	assertions = append(assertions, tc.evaluatePolicies(res, nil)...)
+	for _, a := range assertions {
+		if a.Status == AssertionFailed {
+			return true
+		}
+	}
+	for _, c := range tc.checks {
+		if err := c(res); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// responseMutants returns all mutants of res.
+func responseMutants(res *fnapi.RunFunctionResponse) []mutant {
+	var mutants []mutant
+	if c := res.GetDesired().GetComposite(); c != nil {
+		mutants = append(mutants, structMutants("res.Desired.Composite", c.GetResource(), func(r *fnapi.RunFunctionResponse) *structpb.Struct {
+			return r.GetDesired().GetComposite().GetResource()
+		})...)
+	}
+	for _, name := range maps.SortedKeys(res.GetDesired().GetResources()) {
+		mutants = append(mutants, mutant{
+			description: fmt.Sprintf("removed res.Desired.Resources[%q]", name),
+			mutate: func(r *fnapi.RunFunctionResponse) error {
+				delete(r.GetDesired().GetResources(), name)
+				return nil
+			},
+		})
+		mutants = append(mutants, structMutants(fmt.Sprintf("res.Desired.Resources[%q]", name), res.GetDesired().GetResources()[name].GetResource(), func(r *fnapi.RunFunctionResponse) *structpb.Struct {
+			return r.GetDesired().GetResources()[name].GetResource()
+		})...)
+	}
+	for i := range res.GetResults() {
+		mutants = append(mutants, mutant{
+			description: fmt.Sprintf("removed result %d", i),
+			mutate: func(r *fnapi.RunFunctionResponse) error {
+				r.Results = append(r.Results[:i], r.Results[i+1:]...)
+				return nil
+			},
+		})
+	}
+	if res.GetContext() != nil {
+		mutants = append(mutants, structMutants("res.Context", res.GetContext(), func(r *fnapi.RunFunctionResponse) *structpb.Struct {
+			return r.GetContext()
+		})...)
+	}
+	return mutants
+}
+
+// structMutants returns mutants that remove or change each field of s. The
+// struct of a mutated response is selected by get.
+func structMutants(name string, s *structpb.Struct, get func(r *fnapi.RunFunctionResponse) *structpb.Struct) []mutant {
+	var mutants []mutant
+	for _, p := range leafFieldPaths(s.AsMap(), "") {
+		mutants = append(mutants, mutant{
+			description: fmt.Sprintf("removed field %s of %s", p.path, name),
+			mutate: func(r *fnapi.RunFunctionResponse) error {
+				return mutateStruct(get(r), func(paved *fieldpath.Paved) error {
+					return paved.DeleteField(p.path)
+				})
+			},
+		})
+		changed, ok := changedValue(p.value)
+		if !ok {
+			continue
+		}
+		mutants = append(mutants, mutant{
+			description: fmt.Sprintf("changed field %s of %s to %v", p.path, name, changed),
+			mutate: func(r *fnapi.RunFunctionResponse) error {
+				return mutateStruct(get(r), func(paved *fieldpath.Paved) error {
+					return paved.SetValue(p.path, changed)
+				})
+			},
+		})
+	}
+	return mutants
+}
+
+// mutateStruct applies mutate to the fields of s.
+func mutateStruct(s *structpb.Struct, mutate func(paved *fieldpath.Paved) error) error {
+	obj := s.AsMap()
+	if err := mutate(fieldpath.Pave(obj)); err != nil {
+		return err
+	}
+	mut, err := structpb.NewStruct(obj)
+	if err != nil {
+		return errors.Wrap(err, "cannot convert mutated object")
+	}
+	s.Fields = mut.GetFields()
+	return nil
+}
+
+type leafField struct {
+	path  string
+	value any
+}
+
+// leafFieldPaths returns the paths and values of all leaf fields of v in the
+// field path syntax of Crossplane. Empty objects and arrays are leaves, too.
+func leafFieldPaths(v any, prefix string) []leafField {
+	switch vv := v.(type) {
+	case map[string]any:
+		if len(vv) > 0 {
+			var out []leafField
+			for _, k := range maps.SortedKeys(vv) {
+				out = append(out, leafFieldPaths(vv[k], joinMapPath(prefix, k))...)
+			}
+			return out
+		}
+	case []any:
+		if len(vv) > 0 {
+			var out []leafField
+			for i, e := range vv {
+				out = append(out, leafFieldPaths(e, fmt.Sprintf("%s[%d]", prefix, i))...)
+			}
+			return out
+		}
+	}
+	if prefix == "" {
+		return nil
+	}
+	return []leafField{{path: prefix, value: v}}
+}
+
+// changedValue returns a value of the same type that differs from v. It
+// returns false for empty objects and arrays.
+func changedValue(v any) (any, bool) {
+	switch vv := v.(type) {
+	case string:
+		return vv + "-mutated", true
+	case float64:
+		return vv + 1, true
+	case bool:
+		return !vv, true
+	case nil:
+		return "mutated", true
+	}
+	return nil, false
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithMutationTesting(t *testing.T) {
+	fn := desiredResourcesFunction(t, map[string]map[string]any{
+		"bucket": {"apiVersion": "example.org/v1", "kind": "Bucket", "spec": map[string]any{"region": "eu"}},
+	})
+	bucket := []byte(`{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu}}`)
+
+	cases := map[string]struct {
+		opts         []TestFunctionOpt
+		wantStatus   AssertionStatus
+		wantSurvived []string
+	}{
+		"ExactExpectation": {
+			opts:       []TestFunctionOpt{ExpectDesiredResourceYAML("bucket", bucket)},
+			wantStatus: AssertionPassed,
+		},
+		"LooseExpectation": {
+			opts:       []TestFunctionOpt{AllowExtraDesiredResources(), ExpectJSONPath("bucket", ".spec.region", "eu")},
+			wantStatus: AssertionWarning,
+			wantSurvived: []string{
+				`removed field apiVersion of res.Desired.Resources["bucket"]`,
+				`changed field apiVersion of res.Desired.Resources["bucket"] to example.org/v1-mutated`,
+				`removed field kind of res.Desired.Resources["bucket"]`,
+				`changed field kind of res.Desired.Resources["bucket"] to Bucket-mutated`,
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assertions := EvaluateFunction(t.Name(), fn, append(c.opts, WithMutationTesting())...)
+			if failed := failedAssertions(assertions); len(failed) > 0 {
+				t.Fatalf("want the test to pass, got failed assertions %q", failed)
+			}
+			i := slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == "Mutants" })
+			if i < 0 {
+				t.Fatalf("want a Mutants assertion, got %v", assertions)
+			}
+			if got := assertions[i].Status; got != c.wantStatus {
+				t.Errorf("want status %s, got %s: %s", c.wantStatus, got, assertions[i])
+			}
+			var survived []string
+			if d := assertions[i].Diff; d != "" {
+				survived = strings.Split(strings.TrimPrefix(d, "  "), "\n  ")
+			}
+			if diff := cmp.Diff(c.wantSurvived, survived); diff != "" {
+				t.Errorf("surviving mutants: -want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithMutationTestingDisabled(t *testing.T) {
+	t.Setenv(EnvMutation, "")
+	fn := desiredResourcesFunction(t, map[string]map[string]any{})
+	for _, a := range EvaluateFunction(t.Name(), fn) {
+		if a.Name == "Mutants" {
+			t.Errorf("want no Mutants assertion without mutation testing, got %s", a)
+		}
+	}
+}
+
+func TestLeafFieldPaths(t *testing.T) {
+	v := map[string]any{
+		"spec": map[string]any{
+			"list":  []any{"a", map[string]any{"b": true}},
+			"empty": map[string]any{},
+		},
+		"metadata": map[string]any{
+			"annotations": map[string]any{"example.org/name": "x"},
+		},
+	}
+	want := []leafField{
+		{path: "metadata.annotations[example.org/name]", value: "x"},
+		{path: "spec.empty", value: map[string]any{}},
+		{path: "spec.list[0]", value: "a"},
+		{path: "spec.list[1].b", value: true},
+	}
+	if diff := cmp.Diff(want, leafFieldPaths(v, ""), cmp.AllowUnexported(leafField{})); diff != "" {
+		t.Errorf("leafFieldPaths(...): -want +got\n%s", diff)
+	}
+	if got := leafFieldPaths(map[string]any{}, ""); got != nil {
+		t.Errorf("leafFieldPaths(...) of an empty object: want nil, got %v", got)
+	}
+}
+
+func TestChangedValue(t *testing.T) {
+	cases := map[string]struct {
+		v      any
+		want   any
+		wantOK bool
+	}{
+		"String":      {v: "a", want: "a-mutated", wantOK: true},
+		"Number":      {v: float64(1), want: float64(2), wantOK: true},
+		"Bool":        {v: true, want: false, wantOK: true},
+		"Null":        {v: nil, want: "mutated", wantOK: true},
+		"EmptyObject": {v: map[string]any{}},
+		"EmptyArray":  {v: []any{}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := changedValue(c.v)
+			if ok != c.wantOK || got != c.want {
+				t.Errorf("changedValue(%v): want %v, %t, got %v, %t", c.v, c.want, c.wantOK, got, ok)
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@
 package testing
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	fncontext "github.com/crossplane/function-sdk-go/context"
@@ -13,6 +15,8 @@ import (
 	"github.com/crossplane/function-sdk-go/resource"
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -22,6 +26,16 @@ import (
 	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
 )
 
+// WithContext sets the context that is passed to the function.
+func WithContext(ctx context.Context) TestFunctionOpt {
+	return func(tc *FunctionTest) { tc.reqCtx = ctx }
+}
+
+// WithTimeout sets a timeout on the context that is passed to the function.
+func WithTimeout(d time.Duration) TestFunctionOpt {
+	return func(tc *FunctionTest) { tc.timeout = d }
+}
+
 // WithContextValue sets the expected context field to value.
 func WithContextValue(key string, value any) TestFunctionOpt {
 	return func(tc *FunctionTest) {
@@ -57,7 +71,7 @@ func WithInput(input runtime.Object) TestFunctionOpt {
 		panic(err)
 	}
 	return func(tc *FunctionTest) {
-		tc.req.Input = str
+		tc.req.Input = proto.Clone(str).(*structpb.Struct) //nolint:forcetypeassert // Clone returns the same type.
 	}
 }
 
@@ -114,6 +128,10 @@ func WithObservedResourceJSON(name string, rawJSON []byte) TestFunctionOpt {
 // resource name.
 const AnnotationKeyResourceName = "fn.test/resource-name"
 
+// annotationKeyCompositionResourceName is the key of the annotation that
+// Crossplane sets to the name of a composed resource.
+const annotationKeyCompositionResourceName = "crossplane.io/composition-resource-name"
+
 type AnnotatedObject interface {
 	GetAnnotations() map[string]string
 }
@@ -126,7 +144,7 @@ func GetTestResourceName(o AnnotatedObject) string {
 	if testAnn, exists := ann[AnnotationKeyResourceName]; exists {
 		return testAnn
 	}
-	return ann["crossplane.io/composition-resource-name"]
+	return ann[annotationKeyCompositionResourceName]
 }
 
 // WithObservedResourcesYAML reads all objects from a multi-document YAML and
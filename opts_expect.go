@@ -164,11 +164,22 @@ func IgnoreDesiredResources(names ...string) TestFunctionOpt {
 	}
 }
 
+// AllowExtraDesiredResources reports desired resources of the function that
+// are not expected as warning instead of failing the test. Expected resources
+// are still compared and must not be missing.
+func AllowExtraDesiredResources() TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.allowExtraResources = true
+	}
+}
+
 // ExpectedDesiredResourcesYAML reads all objects from a multi-document YAML and
 // expected them as desired resources from the function.
 //
 // It uses the annotation [AnnotationKeyResourceName] to determine
-// the name of the resource.
+// the name of the resource. Metadata that only contained the annotation is
+// removed, so the files written by [WithArtifactsOnFailure] match resources
+// without metadata.
 func ExpectDesiredResourcesYAML(rawYAML []byte, mods ...ResourceModifier) TestFunctionOpt {
 	return func(tc *FunctionTest) {
 		uList, err := yaml.UnmarshalObjects[*unstructured.Unstructured](rawYAML)
@@ -181,6 +192,12 @@ func ExpectDesiredResourcesYAML(rawYAML []byte, mods ...ResourceModifier) TestFu
 				panic("resource has no name annotation")
 			}
 			meta.RemoveAnnotations(u, AnnotationKeyResourceName)
+			if len(u.GetAnnotations()) == 0 {
+				u.SetAnnotations(nil)
+			}
+			if m, ok := u.Object["metadata"].(map[string]interface{}); ok && len(m) == 0 {
+				delete(u.Object, "metadata")
+			}
 
 			str := mustObjectAsStruct(u)
 			res := &fnapi.Resource{
@@ -243,6 +260,24 @@ func ExpectResults(results []*fnapi.Result) TestFunctionOpt {
 	return func(tc *FunctionTest) { tc.res.Results = results }
 }
 
+// ExpectContextValue expects the context field of the response to equal
+// value. Context fields without expectation are not compared.
+func ExpectContextValue(key string, value any) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.res.Context.Fields[key] = mustStructValue(value)
+	}
+}
+
+// ExpectContextValueYAML is the same as [ExpectContextValue] but reads the
+// value from a single YAML document.
+func ExpectContextValueYAML(key string, rawYAML []byte) TestFunctionOpt {
+	var val any
+	if err := yaml.Unmarshal(rawYAML, &val); err != nil {
+		panic(err.Error())
+	}
+	return ExpectContextValue(key, val)
+}
+
 // ExpectError expects an error from a TestFunctionOpt.
 func ExpectError(err error) TestFunctionOpt {
 	return func(tc *FunctionTest) { tc.err = err }
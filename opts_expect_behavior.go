@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ExpectDeterministic runs the function n times in total with a deep copy of
+// the request and expects every response to be equal to the first one. It
+// panics if n is less than 2.
+//
+// This detects output that depends on the iteration order of maps, which
+// causes endless updates in a cluster.
+func ExpectDeterministic(n int) TestFunctionOpt {
+	return expectDeterministic(n, false)
+}
+
+// ExpectDeterministicShuffled is the same as [ExpectDeterministic] but
+// additionally shuffles the order of the items of every extra resources
+// selection for each run after the first. Crossplane does not guarantee the
+// order of extra resources, so the output must not depend on it.
+func ExpectDeterministicShuffled(n int) TestFunctionOpt {
+	return expectDeterministic(n, true)
+}
+
+func expectDeterministic(n int, shuffle bool) TestFunctionOpt {
+	if n < 2 {
+		panic(fmt.Sprintf("cannot compare %d runs, expected at least 2", n))
+	}
+	return func(tc *FunctionTest) {
+		tc.behaviorChecks = append(tc.behaviorChecks, func(res *fnapi.RunFunctionResponse) error {
+			for run := 2; run <= n; run++ {
+				r := proto.Clone(tc.req).(*fnapi.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+				if shuffle {
+					shuffleExtraResources(r)
+				}
+				got, err := tc.runFunction(r)
+				if err != nil {
+					return errors.Wrapf(err, "Nondeterministic output: run %d returned an error", run)
+				}
+				got = normalizeResponse(got)
+				if diff := cmp.Diff(res, got, protocmp.Transform()); diff != "" {
+					return errors.Errorf("Nondeterministic output: run 1 and %d differ: -run 1 +run %d\n%s", run, run, diff)
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// ExpectNoRequestMutation expects that the function does not modify the
+// request it is passed. The function always receives a copy of the request,
+// so a mutation does not affect other expectations, but it may affect
+// subsequent functions of a pipeline.
+func ExpectNoRequestMutation() TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.runChecks = append(tc.runChecks, func() error {
+			if proto.Equal(tc.req, tc.sentReq) {
+				return nil
+			}
+			paths, err := protoDiffPaths(tc.req, tc.sentReq)
+			if err != nil {
+				return errors.Wrap(err, "Function mutated the request")
+			}
+			return errors.Errorf("Function mutated the request at:\n  %s", strings.Join(paths, "\n  "))
+		})
+	}
+}
+
+// ExpectIdempotent reruns the function with the desired state and context of
+// its first response injected into the request and expects the desired state
+// to be unchanged.
+//
+// A correct step of a pipeline produces the same output when its own output
+// is passed to it as desired state.
+func ExpectIdempotent() TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.behaviorChecks = append(tc.behaviorChecks, func(res *fnapi.RunFunctionResponse) error {
+			req := proto.Clone(tc.req).(*fnapi.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+			if d := res.GetDesired(); d != nil {
+				req.Desired = proto.Clone(d).(*fnapi.State) //nolint:forcetypeassert // Clone returns the same type.
+			}
+			if c := res.GetContext(); c != nil {
+				req.Context = proto.Clone(c).(*structpb.Struct) //nolint:forcetypeassert // Clone returns the same type.
+			}
+			got, err := tc.runFunction(req)
+			if err != nil {
+				return errors.Wrap(err, "Not idempotent: second run returned an error")
+			}
+			got = normalizeResponse(got)
+			if diff := cmp.Diff(res.GetDesired(), got.GetDesired(), protocmp.Transform()); diff != "" {
+				return errors.Errorf("Not idempotent: desired state changed when rerun with its own output: -first +second\n%s", diff)
+			}
+			return nil
+		})
+	}
+}
+
+// ExpectCompletesWithin expects the function to return within d. If it does
+// not, its context is cancelled and the test fails. The failure lists the
+// goroutines that are still running if the function does not honour the
+// cancellation.
+//
+// Use it with a d below the function timeout of Crossplane.
+func ExpectCompletesWithin(d time.Duration) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.completeWithin = d
+		tc.runChecks = append(tc.runChecks, func() error {
+			return tc.completionErr
+		})
+	}
+}
+
+// ExpectNoGoroutineLeaks expects that all goroutines started by the function
+// exit within the grace period after the function returned and its context
+// was cancelled. The failure lists the stack traces of the leaked goroutines.
+//
+// Goroutines of parallel tests are ignored, but goroutines they start while
+// the function is running may be reported as leaks.
+func ExpectNoGoroutineLeaks(grace time.Duration) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.leakGracePeriod = grace
+		tc.runChecks = append(tc.runChecks, func() error {
+			if len(tc.leakedGoroutines) == 0 {
+				return nil
+			}
+			return errors.Errorf("Function leaked %d goroutines that are still running %s after it returned:\n\n%s",
+				len(tc.leakedGoroutines), grace, strings.Join(tc.leakedGoroutines, "\n\n"))
+		})
+	}
+}
+
+// ExpectPanic expects the function to panic with a value that matches. A nil
+// matcher matches any value. No other expectation is evaluated, as the
+// function does not return a response.
+//
+// Without it, a panic fails the test and the request is written to a
+// temporary file to reproduce it.
+func ExpectPanic(matches func(v any) bool) TestFunctionOpt {
+	if matches == nil {
+		matches = func(any) bool { return true }
+	}
+	return func(tc *FunctionTest) {
+		tc.expectPanic = matches
+	}
+}
+
+// PanicMessageContains returns a matcher for [ExpectPanic] that matches
+// values whose message contains substr. The message of an error is the
+// result of its Error method, other values are formatted with [fmt.Sprint].
+func PanicMessageContains(substr string) func(v any) bool {
+	return func(v any) bool {
+		if err, ok := v.(error); ok {
+			return strings.Contains(err.Error(), substr)
+		}
+		return strings.Contains(fmt.Sprint(v), substr)
+	}
+}
+
+// DefaultMaxMessageSize is the default maximum size in bytes of a message
+// that a gRPC server or client receives.
+const DefaultMaxMessageSize = 4 << 20
+
+// ExpectMessageSizeWithin expects the serialized request passed to the
+// function and its response to be at most maxBytes large. Use it with
+// [DefaultMaxMessageSize] to detect compositions that exceed the limits of
+// gRPC.
+func ExpectMessageSizeWithin(maxBytes int) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.runChecks = append(tc.runChecks, func() error {
+			if size := proto.Size(tc.sentReq); size > maxBytes {
+				return errors.Errorf("Request size of %d bytes exceeds the maximum of %d bytes", size, maxBytes)
+			}
+			return nil
+		})
+		tc.checks = append(tc.checks, func(res *fnapi.RunFunctionResponse) error {
+			if size := proto.Size(res); size > maxBytes {
+				return errors.Errorf("Response size of %d bytes exceeds the maximum of %d bytes", size, maxBytes)
+			}
+			return nil
+		})
+	}
+}
+
+// shuffleExtraResources shuffles the items of every extra resources selection
+// of req.
+func shuffleExtraResources(req *fnapi.RunFunctionRequest) {
+	for _, rs := range req.GetExtraResources() {
+		items := rs.GetItems()
+		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+	}
+}
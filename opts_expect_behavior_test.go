@@ -0,0 +1,374 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/response"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// withExtraResources adds the given names as extra resources of the
+// selection "buckets" to the request.
+func withExtraResources(names ...string) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		rs := &fnapi.Resources{}
+		for _, name := range names {
+			u := &unstructured.Unstructured{}
+			u.SetAPIVersion("example.org/v1")
+			u.SetKind("Bucket")
+			u.SetName(name)
+			rs.Items = append(rs.Items, &fnapi.Resource{Resource: mustObjectAsStruct(u)})
+		}
+		tc.req.ExtraResources["buckets"] = rs
+	}
+}
+
+// contextFunction is a function whose use of its context is defined by a
+// test.
+type contextFunction struct {
+	fnapi.UnimplementedFunctionRunnerServiceServer
+
+	run func(ctx context.Context)
+}
+
+func (f *contextFunction) RunFunction(ctx context.Context, req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+	f.run(ctx)
+	return response.To(req, response.DefaultTTL), nil
+}
+
+func TestExpectDeterministic(t *testing.T) {
+	runs := 0
+	counting := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		runs++
+		return response.To(req, response.DefaultTTL), nil
+	}}
+	nondeterministic := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		runs++
+		res := response.To(req, response.DefaultTTL)
+		response.Normal(res, fmt.Sprintf("run %d", runs))
+		return res, nil
+	}}
+	// firstExtraResource reports the name of the first extra resource, which
+	// depends on their order.
+	firstExtraResource := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		runs++
+		res := response.To(req, response.DefaultTTL)
+		name := req.GetExtraResources()["buckets"].GetItems()[0].GetResource().AsMap()["metadata"].(map[string]any)["name"]
+		response.Normal(res, fmt.Sprint(name))
+		return res, nil
+	}}
+	// noDesired returns a response without desired state, which is
+	// normalized to an empty desired state for the first run.
+	noDesired := &testFunction{run: func(*fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		runs++
+		return &fnapi.RunFunctionResponse{}, nil
+	}}
+
+	cases := map[string]struct {
+		fn       *testFunction
+		opt      TestFunctionOpt
+		wantRuns int
+		wantErr  string
+	}{
+		"Deterministic": {
+			fn:       counting,
+			opt:      ExpectDeterministic(3),
+			wantRuns: 3,
+		},
+		"Nondeterministic": {
+			fn:       nondeterministic,
+			opt:      ExpectDeterministic(3),
+			wantRuns: 2,
+			wantErr:  "Nondeterministic output: run 1 and 2 differ",
+		},
+		"NoDesiredState": {
+			fn:       noDesired,
+			opt:      ExpectDeterministic(3),
+			wantRuns: 3,
+		},
+		"OrderOfExtraResourcesNotShuffled": {
+			fn:       firstExtraResource,
+			opt:      ExpectDeterministic(20),
+			wantRuns: 20,
+		},
+		"OrderOfExtraResourcesShuffled": {
+			fn:      firstExtraResource,
+			opt:     ExpectDeterministicShuffled(20),
+			wantErr: "Nondeterministic output",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			runs = 0
+			assertions := EvaluateFunction(t.Name(), c.fn, c.opt, withExtraResources("a", "b", "c"))
+			got := ""
+			if i := slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == "Expectations" }); i >= 0 {
+				got = assertions[i].Message
+			}
+			if c.wantErr == "" && got != "" || !strings.Contains(got, c.wantErr) {
+				t.Errorf("want error %q, got %q", c.wantErr, got)
+			}
+			if c.wantRuns > 0 && runs != c.wantRuns {
+				t.Errorf("want %d runs, got %d", c.wantRuns, runs)
+			}
+		})
+	}
+}
+
+func TestExpectDeterministicTooFewRuns(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("want a panic for n < 2")
+		}
+	}()
+	ExpectDeterministic(1)
+}
+
+func TestExpectIdempotent(t *testing.T) {
+	appending := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		res := response.To(req, response.DefaultTTL)
+		if res.Desired.Resources == nil {
+			res.Desired.Resources = map[string]*fnapi.Resource{}
+		}
+		name := fmt.Sprintf("bucket-%d", len(res.Desired.Resources))
+		res.Desired.Resources[name] = &fnapi.Resource{Resource: mustObjectAsStruct(&unstructured.Unstructured{Object: map[string]any{"apiVersion": "example.org/v1", "kind": "Bucket"}})}
+		return res, nil
+	}}
+	noDesired := &testFunction{run: func(*fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		return &fnapi.RunFunctionResponse{}, nil
+	}}
+
+	cases := map[string]struct {
+		fn      *testFunction
+		wantErr string
+	}{
+		"NotIdempotent": {
+			fn:      appending,
+			wantErr: "Not idempotent",
+		},
+		"NoDesiredState": {
+			fn: noDesired,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assertions := EvaluateFunction(t.Name(), c.fn, ExpectIdempotent(), AllowExtraDesiredResources())
+			i := slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == "Expectations" })
+			if i < 0 {
+				t.Fatalf("expectations were not evaluated: %v", assertions)
+			}
+			if got := assertions[i].Message; c.wantErr == "" && got != "" || !strings.Contains(got, c.wantErr) {
+				t.Errorf("want error %q, got %q", c.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestExpectNoRequestMutation(t *testing.T) {
+	mutating := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		req.GetObserved().GetComposite().GetResource().GetFields()["kind"] = mustStructValue("Mutated")
+		return response.To(req, response.DefaultTTL), nil
+	}}
+	assertions := EvaluateFunction(t.Name(), mutating, ExpectNoRequestMutation(),
+		WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}}`)),
+		ExpectDesiredCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: Mutated, metadata: {name: xr}}`)))
+	i := slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == "Expectations" })
+	if i < 0 || !strings.Contains(assertions[i].Message, "Function mutated the request") {
+		t.Errorf("want the mutation to be reported, got %v", assertions)
+	}
+}
+
+func TestExpectCompletesWithin(t *testing.T) {
+	// release unblocks the function that ignores the cancellation once the
+	// test finished.
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	cases := map[string]struct {
+		run     func(ctx context.Context)
+		wantErr string
+	}{
+		"Completes": {
+			run: func(context.Context) {},
+		},
+		"ReturnsWhenCancelled": {
+			run:     func(ctx context.Context) { <-ctx.Done() },
+			wantErr: "function did not complete within 50ms, it returned only after its context was cancelled",
+		},
+		"IgnoresCancellation": {
+			run:     func(context.Context) { <-release },
+			wantErr: "function did not complete within 50ms and did not return within 1s after its context was cancelled; goroutines still running:",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assertions := EvaluateFunction(t.Name(), &contextFunction{run: c.run}, ExpectCompletesWithin(50*time.Millisecond))
+			i := slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == "Expectations" })
+			if i < 0 {
+				t.Fatalf("expectations were not evaluated: %v", assertions)
+			}
+			if got := assertions[i].Message; c.wantErr == "" && got != "" || !strings.Contains(got, c.wantErr) {
+				t.Errorf("want error %q, got %q", c.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestExpectNoGoroutineLeaks(t *testing.T) {
+	// release stops the leaked goroutine once the test finished.
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	cases := map[string]struct {
+		run     func(ctx context.Context)
+		wantErr string
+	}{
+		"NoGoroutines": {
+			run: func(context.Context) {},
+		},
+		"StopsWithContext": {
+			run: func(ctx context.Context) { go func() { <-ctx.Done() }() },
+		},
+		"Leaks": {
+			run:     func(context.Context) { go func() { <-release }() },
+			wantErr: "Function leaked 1 goroutines that are still running 50ms after it returned:",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assertions := EvaluateFunction(t.Name(), &contextFunction{run: c.run}, ExpectNoGoroutineLeaks(50*time.Millisecond))
+			i := slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == "Expectations" })
+			if i < 0 {
+				t.Fatalf("expectations were not evaluated: %v", assertions)
+			}
+			if got := assertions[i].Message; c.wantErr == "" && got != "" || !strings.Contains(got, c.wantErr) {
+				t.Errorf("want error %q, got %q", c.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestExpectPanic(t *testing.T) {
+	panicking := &testFunction{run: func(*fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		panic("boom")
+	}}
+	returning := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		return response.To(req, response.DefaultTTL), nil
+	}}
+
+	cases := map[string]struct {
+		fn         *testFunction
+		opts       []TestFunctionOpt
+		wantErr    string
+		wantFatal  bool
+		wantFailed []string
+	}{
+		"Unexpected": {
+			fn:         panicking,
+			wantErr:    "Function panicked: boom",
+			wantFatal:  true,
+			wantFailed: []string{"Panic"},
+		},
+		"Expected": {
+			fn:   panicking,
+			opts: []TestFunctionOpt{ExpectPanic(nil)},
+		},
+		"ExpectedMessage": {
+			fn:   panicking,
+			opts: []TestFunctionOpt{ExpectPanic(PanicMessageContains("boo"))},
+		},
+		"UnexpectedMessage": {
+			fn:         panicking,
+			opts:       []TestFunctionOpt{ExpectPanic(PanicMessageContains("bang"))},
+			wantErr:    "Function panicked with unexpected value: boom",
+			wantFailed: []string{"Panic"},
+		},
+		"NoPanic": {
+			fn:         returning,
+			opts:       []TestFunctionOpt{ExpectPanic(nil)},
+			wantErr:    "Expected function to panic, but it returned (error: <nil>)",
+			wantFailed: []string{"Panic"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			// The request of an unexpected panic is written to a temporary
+			// file.
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			opts := append([]TestFunctionOpt{WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}}`))}, c.opts...)
+
+			ft := &fakeTB{TB: t}
+			ft.run(func() { runFunctionTest(ft, c.fn, opts...) })
+			switch {
+			case c.wantErr == "" && len(ft.errors) > 0:
+				t.Errorf("want no failure, got %q", ft.errors)
+			case c.wantErr != "" && (len(ft.errors) != 1 || !strings.HasPrefix(ft.errors[0], c.wantErr)):
+				t.Errorf("want failure %q, got %q", c.wantErr, ft.errors)
+			}
+			if ft.fatal != c.wantFatal {
+				t.Errorf("want fatal %t, got %t", c.wantFatal, ft.fatal)
+			}
+
+			files, err := filepath.Glob(filepath.Join(tmp, "*-request-*.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case !c.wantFatal && len(files) > 0:
+				t.Errorf("want no request file, got %q", files)
+			case c.wantFatal && len(files) != 1:
+				t.Errorf("want a request file, got %q", files)
+			case c.wantFatal:
+				if !strings.Contains(ft.errors[0], "Request written to "+files[0]) {
+					t.Errorf("want the path of the request file in the failure, got %q", ft.errors[0])
+				}
+				raw, err := os.ReadFile(files[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(raw), "kind: XBucket") {
+					t.Errorf("want the observed composite in the request file, got:\n%s", raw)
+				}
+			}
+
+			if got := failedAssertions(EvaluateFunction(t.Name(), c.fn, opts...)); !slices.Equal(got, c.wantFailed) {
+				t.Errorf("EvaluateFunction: want failed assertions %q, got %q", c.wantFailed, got)
+			}
+		})
+	}
+}
+
+func TestPanicMessageContains(t *testing.T) {
+	cases := map[string]struct {
+		value any
+		want  bool
+	}{
+		"Error":           {value: errors.New("cannot compose bucket"), want: true},
+		"ErrorMismatch":   {value: errors.New("cannot compose table"), want: false},
+		"String":          {value: "cannot compose bucket", want: true},
+		"WrappedError":    {value: fmt.Errorf("wrapped: %w", errors.New("cannot compose bucket")), want: true},
+		"OtherValue":      {value: []string{"compose", "table"}, want: false},
+		"OtherValueMatch": {value: struct{ Msg string }{"compose bucket"}, want: true},
+	}
+	matches := PanicMessageContains("compose bucket")
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := matches(c.value); got != c.want {
+				t.Errorf("want %t for %#v, got %t", c.want, c.value, got)
+			}
+		})
+	}
+}
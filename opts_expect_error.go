@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ExpectErrorContains expects the function to return an error whose message
+// contains substr.
+//
+// Like all error expectations except [ExpectError], it replaces the exact
+// comparison of the error and can be combined with other error expectations.
+func ExpectErrorContains(substr string) TestFunctionOpt {
+	return withErrorCheck(func(err error) error {
+		if !strings.Contains(err.Error(), substr) {
+			return errors.Errorf("expected message to contain %q, got %q", substr, err.Error())
+		}
+		return nil
+	})
+}
+
+// ExpectErrorIs expects the function to return an error that matches target
+// according to [errors.Is], e.g. a sentinel error wrapped with
+// [errors.Wrap].
+func ExpectErrorIs(target error) TestFunctionOpt {
+	return withErrorCheck(func(err error) error {
+		if !errors.Is(err, target) {
+			return errors.Errorf("expected %q to wrap %q", err.Error(), target.Error())
+		}
+		return nil
+	})
+}
+
+// ExpectErrorAs expects the function to return an error of type T or one
+// that wraps an error of type T according to [errors.As].
+func ExpectErrorAs[T error]() TestFunctionOpt {
+	return withErrorCheck(func(err error) error {
+		var target T
+		if !errors.As(err, &target) {
+			return errors.Errorf("expected %q to be or wrap a %s", err.Error(), reflect.TypeFor[T]().String())
+		}
+		return nil
+	})
+}
+
+// ExpectGRPCStatus expects the function to return an error with a gRPC status
+// of the given code whose message matches msgRegexp. An empty msgRegexp
+// matches any message.
+func ExpectGRPCStatus(code codes.Code, msgRegexp string) TestFunctionOpt {
+	re := regexp.MustCompile(msgRegexp)
+	return withErrorCheck(func(err error) error {
+		// status.FromError would use the message of the wrapping error.
+		var se interface{ GRPCStatus() *status.Status }
+		if !errors.As(err, &se) {
+			return errors.Errorf("expected a gRPC status, got %q", err.Error())
+		}
+		s := se.GRPCStatus()
+		var failed []string
+		if s.Code() != code {
+			failed = append(failed, fmt.Sprintf("expected code %s, got %s", code, s.Code()))
+		}
+		if !re.MatchString(s.Message()) {
+			failed = append(failed, fmt.Sprintf("expected message to match %q, got %q", msgRegexp, s.Message()))
+		}
+		if len(failed) > 0 {
+			return errors.Errorf("gRPC status: %s", strings.Join(failed, "; "))
+		}
+		return nil
+	})
+}
+
+// withErrorCheck adds check to the error expectations. The test fails if the
+// function returns no error at all.
+func withErrorCheck(check func(err error) error) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.errChecks = append(tc.errChecks, func(err error) error {
+			if err == nil {
+				return errors.New("expected an error, got none")
+			}
+			return check(err)
+		})
+	}
+}
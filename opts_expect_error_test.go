@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"slices"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errTestSentinel = errors.New("sentinel")
+
+type testError struct{}
+
+func (testError) Error() string { return "test error" }
+
+func TestExpectErrors(t *testing.T) {
+	// failing returns err without a response, like most functions do.
+	failing := func(err error) *testFunction {
+		return &testFunction{run: func(*fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+			return nil, err
+		}}
+	}
+	bucket := []byte(`{apiVersion: example.org/v1, kind: Bucket}`)
+
+	cases := map[string]struct {
+		fn         *testFunction
+		opts       []TestFunctionOpt
+		wantFailed []string
+	}{
+		"Contains": {
+			fn:   failing(errors.Wrap(errTestSentinel, "cannot compose")),
+			opts: []TestFunctionOpt{ExpectErrorContains("cannot compose")},
+		},
+		"ContainsMismatch": {
+			fn:         failing(errors.New("boom")),
+			opts:       []TestFunctionOpt{ExpectErrorContains("cannot compose")},
+			wantFailed: []string{"Error"},
+		},
+		"Is": {
+			fn:   failing(errors.Wrap(errTestSentinel, "cannot compose")),
+			opts: []TestFunctionOpt{ExpectErrorIs(errTestSentinel), ExpectErrorContains("cannot compose")},
+		},
+		"IsMismatch": {
+			fn:         failing(errors.New("sentinel")),
+			opts:       []TestFunctionOpt{ExpectErrorIs(errTestSentinel)},
+			wantFailed: []string{"Error"},
+		},
+		"As": {
+			fn:   failing(errors.Wrap(testError{}, "cannot compose")),
+			opts: []TestFunctionOpt{ExpectErrorAs[testError]()},
+		},
+		"GRPCStatus": {
+			fn:   failing(status.Error(codes.InvalidArgument, "invalid input")),
+			opts: []TestFunctionOpt{ExpectGRPCStatus(codes.InvalidArgument, "^invalid")},
+		},
+		"GRPCStatusMismatch": {
+			fn:         failing(status.Error(codes.Internal, "invalid input")),
+			opts:       []TestFunctionOpt{ExpectGRPCStatus(codes.InvalidArgument, "")},
+			wantFailed: []string{"Error"},
+		},
+		"NoError": {
+			fn: &testFunction{run: func(*fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+				return &fnapi.RunFunctionResponse{}, nil
+			}},
+			opts:       []TestFunctionOpt{ExpectErrorContains("boom")},
+			wantFailed: []string{"Error"},
+		},
+		"MissingDesiredResources": {
+			fn:         failing(errors.New("boom")),
+			opts:       []TestFunctionOpt{ExpectErrorContains("boom"), ExpectDesiredResourceYAML("bucket", bucket)},
+			wantFailed: []string{"res.Desired.Resources"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := failedAssertions(EvaluateFunction(t.Name(), c.fn, c.opts...))
+			if !slices.Equal(got, c.wantFailed) {
+				t.Errorf("want failed assertions %q, got %q", c.wantFailed, got)
+			}
+		})
+	}
+}
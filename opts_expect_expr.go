@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	celVarObject = "object"
+	celVarName   = "name"
+)
+
+// ExpectCEL expects the CEL expression to evaluate to true for every desired
+// composed resource whose name matches resourceSelector.
+//
+// The selector uses the pattern syntax of [path.Match], so "*" selects all
+// desired composed resources. The expression can access the resource as
+// `object` and its name as `name`, e.g.
+//
+//	ExpectCEL("*", `object.metadata.labels.team == "x"`)
+//	ExpectCEL("bucket-*", `object.spec.forProvider.encrypted`)
+//
+// The expectation fails if no desired resource matches the selector.
+func ExpectCEL(resourceSelector, expression string) TestFunctionOpt {
+	if _, err := path.Match(resourceSelector, ""); err != nil {
+		panic(errors.Wrapf(err, "invalid resource selector %q", resourceSelector).Error())
+	}
+	prg, err := compileCELExpression(expression)
+	if err != nil {
+		panic(err.Error())
+	}
+	return func(tc *FunctionTest) {
+		tc.checks = append(tc.checks, func(res *fnapi.RunFunctionResponse) error {
+			names, err := evaluateCELExpression(prg, resourceSelector, expression, res.GetDesired().GetResources())
+			tc.checkResources(names...)
+			return err
+		})
+	}
+}
+
+// ExpectJSONPath expects that the JSONPath expression evaluates to value on
+// the desired composed resource with the given name.
+//
+// The path uses the kubectl JSONPath syntax, e.g. ".spec.forProvider.region"
+// or "{.spec.tags[?(@.key=='team')].value}". The value is compared by its JSON
+// representation. If the path matches multiple fields, value must be a list of
+// all matched values.
+func ExpectJSONPath(name, jsonPath string, value any) TestFunctionOpt {
+	if _, err := parseJSONPath(name, jsonPath); err != nil {
+		panic(err.Error())
+	}
+	want, err := normalizeJSONValue(value)
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid value for JSONPath %q", jsonPath).Error())
+	}
+	return func(tc *FunctionTest) {
+		tc.checks = append(tc.checks, func(res *fnapi.RunFunctionResponse) error {
+			// A JSONPath keeps state while it is evaluated, so it must not be
+			// shared between tests that may run in parallel.
+			jp, err := parseJSONPath(name, jsonPath)
+			if err != nil {
+				return err
+			}
+			tc.checkResources(name)
+			return evaluateJSONPath(jp, name, jsonPath, want, res.GetDesired().GetResources())
+		})
+	}
+}
+
+func compileCELExpression(expression string) (cel.Program, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(celVarObject, cel.DynType),
+		cel.Variable(celVarName, cel.StringType),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create CEL environment")
+	}
+	ast, iss := env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, errors.Wrapf(iss.Err(), "cannot compile CEL expression %q", expression)
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, errors.Errorf("CEL expression %q must evaluate to bool, got %s", expression, ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create program for CEL expression %q", expression)
+	}
+	return prg, nil
+}
+
+// evaluateCELExpression evaluates prg for all resources that match selector
+// and returns their names.
+func evaluateCELExpression(prg cel.Program, selector, expression string, resources map[string]*fnapi.Resource) ([]string, error) {
+	names, failed := evaluateCELProgram(prg, selector, resources)
+	if len(names) == 0 {
+		return nil, errors.Errorf("ExpectCEL(%q, %q): no desired resource matches the selector", selector, expression)
+	}
+	if len(failed) > 0 {
+		return names, errors.Errorf("ExpectCEL(%q, %q) failed for %d of %d resources:\n  %s", selector, expression, len(failed), len(names), strings.Join(failed, "\n  "))
+	}
+	return names, nil
+}
+
+// evaluateCELProgram evaluates prg for all resources that match selector. It
+// returns the names of all matching resources and a description for each
+// resource that violates the expression.
+func evaluateCELProgram(prg cel.Program, selector string, resources map[string]*fnapi.Resource) ([]string, []string) {
+	objects := convertResourcesMapToUnstructured(resources)
+	names := selectResourceNames(objects, selector)
+
+	var failed []string
+	for _, name := range names {
+		out, _, err := prg.Eval(map[string]any{
+			celVarObject: objects[name].UnstructuredContent(),
+			celVarName:   name,
+		})
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		if ok, isBool := out.Value().(bool); !isBool {
+			failed = append(failed, fmt.Sprintf("%s: expression evaluated to %v instead of bool", name, out.Value()))
+		} else if !ok {
+			failed = append(failed, fmt.Sprintf("%s: expression evaluated to false", name))
+		}
+	}
+	return names, failed
+}
+
+// selectResourceNames returns the sorted names of all objects that match the
+// given selector.
+func selectResourceNames(objects map[string]*unstructured.Unstructured, selector string) []string {
+	names := []string{}
+	for name := range objects {
+		if ok, _ := path.Match(selector, name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func evaluateJSONPath(jp *jsonpath.JSONPath, name, jsonPath string, want any, resources map[string]*fnapi.Resource) error {
+	res, exists := resources[name]
+	if !exists {
+		return errors.Errorf("ExpectJSONPath(%q, %q): desired resource does not exist", name, jsonPath)
+	}
+	results, err := jp.FindResults(convertResourceToUnstructured(res).UnstructuredContent())
+	if err != nil {
+		return errors.Errorf("ExpectJSONPath(%q, %q): %s", name, jsonPath, err)
+	}
+	values := []any{}
+	for _, r := range results {
+		for _, v := range r {
+			values = append(values, reflectValueInterface(v))
+		}
+	}
+
+	var got any = values
+	switch len(values) {
+	case 0:
+		return errors.Errorf("ExpectJSONPath(%q, %q): path does not match any field", name, jsonPath)
+	case 1:
+		got = values[0]
+	}
+	got, err = normalizeJSONValue(got)
+	if err != nil {
+		return errors.Errorf("ExpectJSONPath(%q, %q): %s", name, jsonPath, err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		return errors.Errorf("ExpectJSONPath(%q, %q): -want +got\n%s", name, jsonPath, diff)
+	}
+	return nil
+}
+
+func parseJSONPath(name, jsonPath string) (*jsonpath.JSONPath, error) {
+	jp := jsonpath.New(name)
+	if err := jp.Parse(normalizeJSONPath(jsonPath)); err != nil {
+		return nil, errors.Wrapf(err, "invalid JSONPath %q", jsonPath)
+	}
+	return jp, nil
+}
+
+// normalizeJSONPath wraps the path in curly braces if it isn't a template
+// already.
+func normalizeJSONPath(p string) string {
+	if strings.HasPrefix(p, "{") {
+		return p
+	}
+	return "{" + p + "}"
+}
+
+func reflectValueInterface(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Interface && v.IsNil() {
+		return nil
+	}
+	return v.Interface()
+}
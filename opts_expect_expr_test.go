@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpectExpressions(t *testing.T) {
+	fn := desiredResourcesFunction(t, map[string]map[string]any{
+		"bucket-a": {
+			"apiVersion": "example.org/v1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"labels": map[string]any{"team": "a"}},
+			"spec": map[string]any{
+				"region": "eu",
+				"tags":   []any{map[string]any{"key": "team", "value": "a"}, map[string]any{"key": "env", "value": "dev"}},
+			},
+		},
+		"bucket-b": {
+			"apiVersion": "example.org/v1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"labels": map[string]any{"team": "b"}},
+			"spec":       map[string]any{"region": "eu", "size": 2},
+		},
+	})
+
+	cases := map[string]struct {
+		opt TestFunctionOpt
+		// wantErr is contained in the message of the failed expectations. The
+		// expectations pass if it is empty.
+		wantErr string
+	}{
+		"CELAll": {
+			opt: ExpectCEL("*", `object.spec.region == "eu"`),
+		},
+		"CELSelector": {
+			opt: ExpectCEL("bucket-b", `object.metadata.labels.team == "b" && name == "bucket-b"`),
+		},
+		"CELSelectorPattern": {
+			opt:     ExpectCEL("bucket-*", `object.metadata.labels.team == "a"`),
+			wantErr: "failed for 1 of 2 resources:\n  bucket-b: expression evaluated to false",
+		},
+		"CELNonBool": {
+			opt:     ExpectCEL("bucket-a", `object.spec.region`),
+			wantErr: "bucket-a: expression evaluated to eu instead of bool",
+		},
+		"CELMissingField": {
+			opt:     ExpectCEL("bucket-a", `object.spec.size == 2`),
+			wantErr: "bucket-a: no such key: size",
+		},
+		"CELNoMatch": {
+			opt:     ExpectCEL("table-*", `true`),
+			wantErr: `ExpectCEL("table-*", "true"): no desired resource matches the selector`,
+		},
+		"JSONPath": {
+			opt: ExpectJSONPath("bucket-b", ".spec.size", 2),
+		},
+		"JSONPathTemplate": {
+			opt: ExpectJSONPath("bucket-a", "{.spec.tags[?(@.key=='team')].value}", "a"),
+		},
+		"JSONPathMismatch": {
+			opt:     ExpectJSONPath("bucket-a", ".spec.region", "us"),
+			wantErr: `ExpectJSONPath("bucket-a", ".spec.region"): -want +got`,
+		},
+		"JSONPathMultipleValues": {
+			opt: ExpectJSONPath("bucket-a", ".spec.tags[*].key", []string{"team", "env"}),
+		},
+		"JSONPathMultipleValuesMismatch": {
+			opt:     ExpectJSONPath("bucket-a", ".spec.tags[*].key", "team"),
+			wantErr: `ExpectJSONPath("bucket-a", ".spec.tags[*].key"): -want +got`,
+		},
+		"JSONPathNoField": {
+			opt:     ExpectJSONPath("bucket-a", ".spec.size", 1),
+			wantErr: `ExpectJSONPath("bucket-a", ".spec.size"): size is not found`,
+		},
+		"JSONPathMissingResource": {
+			opt:     ExpectJSONPath("table", ".spec.size", 1),
+			wantErr: `ExpectJSONPath("table", ".spec.size"): desired resource does not exist`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *Assertion
+			for _, a := range EvaluateFunction(t.Name(), fn, c.opt) {
+				if a.Name == "Expectations" {
+					got = &a
+				}
+			}
+			switch {
+			case got == nil:
+				t.Fatal("expectations were not evaluated")
+			case c.wantErr == "" && got.Status != AssertionPassed:
+				t.Errorf("want expectations to pass, got %s", got.Message)
+			case c.wantErr != "" && (got.Status != AssertionFailed || !strings.Contains(got.Message, c.wantErr)):
+				t.Errorf("want expectations to fail with %q, got %s: %s", c.wantErr, got.Status, got.Message)
+			}
+		})
+	}
+}
+
+func TestExpectExpressionsInvalid(t *testing.T) {
+	cases := map[string]struct {
+		opt       func() TestFunctionOpt
+		wantPanic string
+	}{
+		"CELSelector": {
+			opt:       func() TestFunctionOpt { return ExpectCEL("[", "true") },
+			wantPanic: `invalid resource selector "["`,
+		},
+		"CELSyntax": {
+			opt:       func() TestFunctionOpt { return ExpectCEL("*", "object.spec ==") },
+			wantPanic: `cannot compile CEL expression "object.spec =="`,
+		},
+		"CELUnknownVariable": {
+			opt:       func() TestFunctionOpt { return ExpectCEL("*", "resource.spec.size > 1") },
+			wantPanic: `cannot compile CEL expression "resource.spec.size > 1"`,
+		},
+		"CELNonBool": {
+			opt:       func() TestFunctionOpt { return ExpectCEL("*", "name + 'x'") },
+			wantPanic: `CEL expression "name + 'x'" must evaluate to bool, got string`,
+		},
+		"JSONPathSyntax": {
+			opt:       func() TestFunctionOpt { return ExpectJSONPath("bucket", ".spec[", "eu") },
+			wantPanic: `invalid JSONPath ".spec["`,
+		},
+		"JSONPathValue": {
+			opt:       func() TestFunctionOpt { return ExpectJSONPath("bucket", ".spec", func() {}) },
+			wantPanic: `invalid value for JSONPath ".spec"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				got, _ := recover().(string)
+				if !strings.Contains(got, c.wantPanic) {
+					t.Errorf("want panic %q, got %q", c.wantPanic, got)
+				}
+			}()
+			c.opt()
+		})
+	}
+}
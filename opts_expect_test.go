@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestExpectDesiredResourcesYAMLOnlyNameAnnotation tests that the metadata of
+// an expected resource is dropped if it only contained the name annotation,
+// like in the expect_composed.yaml artifacts, while the comparison itself
+// still treats empty metadata as a difference.
+func TestExpectDesiredResourcesYAMLOnlyNameAnnotation(t *testing.T) {
+	fn := desiredResourcesFunction(t, map[string]map[string]any{
+		"bucket": {"apiVersion": "example.org/v1", "kind": "Bucket"},
+	})
+	cases := map[string]struct {
+		opt        TestFunctionOpt
+		wantFailed []string
+	}{
+		"OnlyNameAnnotation": {
+			opt: ExpectDesiredResourcesYAML([]byte(`{apiVersion: example.org/v1, kind: Bucket, metadata: {annotations: {fn.test/resource-name: bucket}}}`)),
+		},
+		"OtherAnnotation": {
+			opt:        ExpectDesiredResourcesYAML([]byte(`{apiVersion: example.org/v1, kind: Bucket, metadata: {annotations: {fn.test/resource-name: bucket, team: a}}}`)),
+			wantFailed: []string{`res.Desired.Resources["bucket"]`},
+		},
+		"Name": {
+			opt:        ExpectDesiredResourcesYAML([]byte(`{apiVersion: example.org/v1, kind: Bucket, metadata: {name: b, annotations: {fn.test/resource-name: bucket}}}`)),
+			wantFailed: []string{`res.Desired.Resources["bucket"]`},
+		},
+		"ExplicitEmptyMetadata": {
+			opt:        ExpectDesiredResourceYAML("bucket", []byte(`{apiVersion: example.org/v1, kind: Bucket, metadata: {}}`)),
+			wantFailed: []string{`res.Desired.Resources["bucket"]`},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := failedAssertions(EvaluateFunction(t.Name(), fn, c.opt))
+			if !slices.Equal(got, c.wantFailed) {
+				t.Errorf("want failed assertions %q, got %q", c.wantFailed, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/schema"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+const (
+	kindCRD = "CustomResourceDefinition"
+	kindXRD = "CompositeResourceDefinition"
+)
+
+// WithCRDs loads all CustomResourceDefinitions and CompositeResourceDefinitions
+// from a multi-document YAML and validates the desired composed resources and
+// the desired composite against their schemas.
+//
+// Validation works like in the API server: schema defaults are applied,
+// fields that would be pruned are reported as unknown and the CEL rules of
+// x-kubernetes-validations are evaluated. Because the desired composite is
+// only a partial object, missing required fields are not reported for it.
+// Resources whose kind is not defined by any of the loaded definitions are not
+// validated.
+func WithCRDs(rawYAML []byte) TestFunctionOpt {
+	v := schema.NewValidator()
+	if err := v.AddYAML(rawYAML); err != nil {
+		panic(err.Error())
+	}
+	return withSchemas(v)
+}
+
+// WithCRDsFromDir is the same as [WithCRDs] but loads the definitions from all
+// YAML files in dir and its subdirectories.
+func WithCRDsFromDir(dir string) TestFunctionOpt {
+	v := schema.NewValidator()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		raw, err := os.ReadFile(path) //nolint:gosec // Reading test fixtures.
+		if err != nil {
+			return err
+		}
+		return errors.Wrap(v.AddYAML(raw), path)
+	})
+	if err != nil {
+		panic(errors.Wrapf(err, "cannot load definitions from %s", dir).Error())
+	}
+	return withSchemas(v)
+}
+
+// WithXRD loads the CompositeResourceDefinitions from a multi-document YAML
+// and validates the observed composite against the schema of its version
+// before the function is run. The version must be served by the XRD. Schema
+// defaults are applied to the observed composite as the API server would.
+//
+// Like with [WithCRDs], the desired composite, including the status fields
+// written by the function, is validated against the schema as well.
+func WithXRD(rawYAML []byte) TestFunctionOpt {
+	v := schema.NewValidator()
+	objects, err := yaml.UnmarshalObjects[*unstructured.Unstructured](rawYAML)
+	if err != nil {
+		panic(err.Error())
+	}
+	for _, o := range objects {
+		if o.GetKind() != kindXRD {
+			panic(fmt.Sprintf("object %s is not a %s", o.GetName(), kindXRD))
+		}
+		if err := v.AddObject(o); err != nil {
+			panic(err.Error())
+		}
+	}
+	return func(tc *FunctionTest) {
+		withSchemas(v)(tc)
+		tc.setups = append(tc.setups, func() error {
+			return tc.validateObservedComposite(v)
+		})
+	}
+}
+
+func withSchemas(v *schema.Validator) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		if tc.schemas == nil {
+			tc.schemas = schema.NewValidator()
+			tc.checks = append(tc.checks, tc.validateDesiredState)
+		}
+		tc.schemas.Merge(v)
+	}
+}
+
+// validateObservedComposite validates the observed composite against the
+// schemas of v and applies their defaults.
+func (tc *FunctionTest) validateObservedComposite(v *schema.Validator) error {
+	res := tc.req.GetObserved().GetComposite()
+	if res == nil {
+		return nil
+	}
+	u := convertResourceToUnstructured(res)
+	gvk := u.GroupVersionKind()
+	if !v.Has(gvk) {
+		return errors.Errorf("observed composite: no CompositeResourceDefinition defines %s", gvk.String())
+	}
+	if !v.Served(gvk) {
+		return errors.Errorf("observed composite: version %s is not served", gvk.String())
+	}
+	errs, err := v.Validate(u, schema.ValidateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "observed composite")
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("observed composite (%s) is invalid:\n%s", gvk.String(), schema.FormatErrors(errs, "  "))
+	}
+	v.Default(u)
+	res.Resource = mustObjectAsStruct(u)
+	return nil
+}
+
+// validateDesiredState validates the desired composite and composed
+// resources against the loaded schemas.
+func (tc *FunctionTest) validateDesiredState(res *fnapi.RunFunctionResponse) error {
+	var failed []string
+	if c := res.GetDesired().GetComposite(); c != nil {
+		if msg := tc.validateResource("composite", c, schema.ValidateOptions{IgnoreRequired: true}); msg != "" {
+			failed = append(failed, msg)
+		}
+	}
+
+	resources := res.GetDesired().GetResources()
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if msg := tc.validateResource(fmt.Sprintf("resource %q", name), resources[name], schema.ValidateOptions{}); msg != "" {
+			failed = append(failed, msg)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("Desired state is invalid:\n%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+func (tc *FunctionTest) validateResource(desc string, res *fnapi.Resource, opts schema.ValidateOptions) string {
+	u := convertResourceToUnstructured(res)
+	if !tc.schemas.Has(u.GroupVersionKind()) {
+		return ""
+	}
+	errs, err := tc.schemas.Validate(u, opts)
+	if err != nil {
+		return fmt.Sprintf("  %s (%s): %s", desc, u.GroupVersionKind().String(), err)
+	}
+	if len(errs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  %s (%s):\n%s", desc, u.GroupVersionKind().String(), schema.FormatErrors(errs, "    "))
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testXRD = []byte(`
+apiVersion: apiextensions.crossplane.io/v1
+kind: CompositeResourceDefinition
+metadata:
+  name: xbuckets.example.org
+spec:
+  group: example.org
+  names:
+    kind: XBucket
+    plural: xbuckets
+  versions:
+    - name: v1
+      served: true
+      referenceable: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                region:
+                  type: string
+                  default: eu
+`)
+
+var testCRD = []byte(`
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: buckets.example.org
+spec:
+  group: example.org
+  names:
+    kind: Bucket
+    plural: buckets
+  scope: Cluster
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                region:
+                  type: string
+`)
+
+func TestWithXRD(t *testing.T) {
+	cases := map[string]struct {
+		composite string
+		wantSetup string
+	}{
+		"Valid": {
+			composite: `{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {}}`,
+		},
+		"WithoutSpec": {
+			composite: `{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}}`,
+			wantSetup: "spec: Required value",
+		},
+		"UnknownField": {
+			composite: `{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {zone: a}}`,
+			wantSetup: "spec.zone: Forbidden: unknown field",
+		},
+		"UnknownKind": {
+			composite: `{apiVersion: example.org/v2, kind: XBucket, metadata: {name: xr}, spec: {}}`,
+			wantSetup: "no CompositeResourceDefinition defines example.org/v2, Kind=XBucket",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fn := desiredResourcesFunction(t, nil)
+			assertions := EvaluateFunction(t.Name(), fn, WithXRD(testXRD), WithObservedCompositeYAML([]byte(c.composite)))
+			if c.wantSetup == "" {
+				if failed := failedAssertions(assertions); len(failed) > 0 {
+					t.Errorf("want no failed assertions, got %q", failed)
+				}
+				return
+			}
+			if assertions[0].Name != "Setup" || !strings.Contains(assertions[0].Message, c.wantSetup) {
+				t.Errorf("want setup error %q, got %s", c.wantSetup, assertions[0].String())
+			}
+		})
+	}
+}
+
+func TestWithCRDs(t *testing.T) {
+	fn := desiredResourcesFunction(t, map[string]map[string]any{
+		"valid":   {"apiVersion": "example.org/v1", "kind": "Bucket", "spec": map[string]any{"region": "eu"}},
+		"invalid": {"apiVersion": "example.org/v1", "kind": "Bucket", "spec": map[string]any{"region": 1.0}},
+		"unknown": {"apiVersion": "example.org/v1", "kind": "Unknown"},
+	})
+	assertions := EvaluateFunction(t.Name(), fn, WithCRDs(testCRD), AllowExtraDesiredResources())
+	if got, want := failedAssertions(assertions), []string{"Expectations"}; !slices.Equal(got, want) {
+		t.Fatalf("want failed assertions %q, got %q", want, got)
+	}
+	msg := assertions[slices.IndexFunc(assertions, func(a Assertion) bool { return a.Name == "Expectations" })].Message
+	if !strings.Contains(msg, `resource "invalid"`) || strings.Contains(msg, `resource "valid"`) || strings.Contains(msg, `resource "unknown"`) {
+		t.Errorf("want only resource \"invalid\" to be reported, got:\n%s", msg)
+	}
+}
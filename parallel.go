@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"testing"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+)
+
+// RunCasesParallel runs run for every case as a parallel subtest of t. The
+// subtests are named by the keys of cases and started in their sorted order.
+//
+// Options of [TestFunction] can be shared between the cases, e.g.
+//
+//	base := []fntesting.TestFunctionOpt{
+//		fntesting.WithObservedCompositeYAML(observedComposite),
+//	}
+//	fntesting.RunCasesParallel(t, cases, func(t *testing.T, c testCase) {
+//		fntesting.TestFunction(t, fn, slices.Concat(base, c.opts)...)
+//	})
+func RunCasesParallel[C any](t *testing.T, cases map[string]C, run func(t *testing.T, c C)) {
+	t.Helper()
+	for _, name := range maps.SortedKeys(cases) {
+		c := cases[name]
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			run(t, c)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/crossplane/function-sdk-go/response"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// TestRunCasesParallel reuses one slice of options across parallel cases.
+// Run it with -race to detect options that share state between tests. Cases
+// only run in parallel if -parallel is greater than 1, which is not the
+// default on a single CPU.
+func TestRunCasesParallel(t *testing.T) {
+	// The function copies the labels of the observed resource and the region
+	// of the composite into the desired resource and modifies the request it
+	// is passed in place.
+	fn := &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		// Yield, so parallel cases interleave even on a single CPU.
+		runtime.Gosched()
+		res := response.To(req, response.DefaultTTL)
+		observed := req.GetObserved().GetResources()["bucket"].GetResource().AsMap()
+		region := req.GetObserved().GetComposite().GetResource().AsMap()["spec"].(map[string]any)["region"]
+		bucket, err := structpb.NewStruct(map[string]any{
+			"apiVersion": "example.org/v1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"labels": observed["metadata"].(map[string]any)["labels"]},
+			"spec":       map[string]any{"region": region},
+		})
+		if err != nil {
+			return nil, err
+		}
+		res.Desired.Resources = map[string]*fnapi.Resource{"bucket": {Resource: bucket}}
+		req.GetObserved().GetResources()["bucket"].GetResource().GetFields()["metadata"] = structpb.NewNullValue()
+		return res, nil
+	}}
+
+	base := []TestFunctionOpt{
+		WithXRD(testXRD),
+		WithCRDs(testCRD),
+		WithObservedCompositeYAML([]byte(`{apiVersion: example.org/v1, kind: XBucket, metadata: {name: xr}, spec: {}}`)),
+		WithObservedResourcesYAML([]byte(`
+apiVersion: example.org/v1
+kind: Bucket
+metadata:
+  annotations:
+    fn.test/resource-name: bucket
+  labels:
+    team: a
+`)),
+		WithObservedResourcesYAMLOverride([]byte(`
+apiVersion: example.org/v1
+kind: Bucket
+metadata:
+  annotations:
+    fn.test/resource-name: bucket
+  labels:
+    env: test
+`)),
+		ExpectDesiredResourcesYAML([]byte(`
+apiVersion: example.org/v1
+kind: Bucket
+metadata:
+  annotations:
+    fn.test/resource-name: bucket
+  labels:
+    env: test
+    team: a
+spec:
+  region: eu
+`)),
+		ExpectJSONPath("bucket", ".spec.region", "eu"),
+		ExpectJSONPath("bucket", "{.metadata.labels.team}", "a"),
+	}
+
+	cases := map[string]int{}
+	for i := range 16 {
+		cases[fmt.Sprintf("Case%02d", i)] = i
+	}
+	RunCasesParallel(t, cases, func(t *testing.T, i int) {
+		opts := slices.Concat(base, []TestFunctionOpt{WithContextValue("case", float64(i))})
+		for _, a := range EvaluateFunction(t.Name(), fn, opts...) {
+			if a.Status == AssertionFailed {
+				t.Error(a.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,208 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+)
+
+// Policy is a suite-level check that is evaluated by [TestFunction] against
+// the response of every function run after the response has been compared
+// with the expected outcome. It returns an error that describes the
+// violation, if any.
+type Policy func(res *fnapi.RunFunctionResponse) error
+
+var policies = &policyRegistry{
+	policies: map[string]Policy{},
+}
+
+type policyRegistry struct {
+	mu       sync.RWMutex
+	policies map[string]Policy
+}
+
+// RegisterPolicy registers a policy under the given name. It is typically
+// called from TestMain or an init function of the test package. It panics if
+// a policy with the same name is already registered.
+func RegisterPolicy(name string, p Policy) {
+	policies.mu.Lock()
+	defer policies.mu.Unlock()
+	if _, exists := policies.policies[name]; exists {
+		panic(fmt.Sprintf("policy %q is already registered", name))
+	}
+	policies.policies[name] = p
+}
+
+// RegisterCELPolicy registers a policy that expects the CEL expression to
+// evaluate to true for every desired composed resource whose name matches
+// resourceSelector. See [ExpectCEL] for the syntax of both.
+//
+// Unlike [ExpectCEL], the policy does not fail if no resource matches the
+// selector.
+func RegisterCELPolicy(name, resourceSelector, expression string) {
+	if _, err := path.Match(resourceSelector, ""); err != nil {
+		panic(errors.Wrapf(err, "invalid resource selector %q", resourceSelector).Error())
+	}
+	prg, err := compileCELExpression(expression)
+	if err != nil {
+		panic(err.Error())
+	}
+	RegisterPolicy(name, func(res *fnapi.RunFunctionResponse) error {
+		_, failed := evaluateCELProgram(prg, resourceSelector, res.GetDesired().GetResources())
+		if len(failed) > 0 {
+			return errors.Errorf("%q is violated by:\n  %s", expression, strings.Join(failed, "\n  "))
+		}
+		return nil
+	})
+}
+
+// UnregisterPolicy removes the policy with the given name. It is a noop if no
+// such policy is registered.
+func UnregisterPolicy(name string) {
+	policies.mu.Lock()
+	defer policies.mu.Unlock()
+	delete(policies.policies, name)
+}
+
+// RequireLabels returns a [Policy] that expects all desired composed
+// resources to have the given labels.
+func RequireLabels(keys ...string) Policy {
+	return func(res *fnapi.RunFunctionResponse) error {
+		var failed []string
+		objects := convertResourcesMapToUnstructured(res.GetDesired().GetResources())
+		for _, name := range selectResourceNames(objects, "*") {
+			labels := objects[name].GetLabels()
+			for _, k := range keys {
+				if _, exists := labels[k]; !exists {
+					failed = append(failed, fmt.Sprintf("%s: missing label %q", name, k))
+				}
+			}
+		}
+		return policyViolations(failed)
+	}
+}
+
+// RequireFields returns a [Policy] that expects all desired composed
+// resources to have the fields at the given paths set, e.g.
+// "spec.deletionPolicy" or "spec.providerConfigRef.name".
+func RequireFields(paths ...string) Policy {
+	return func(res *fnapi.RunFunctionResponse) error {
+		var failed []string
+		objects := convertResourcesMapToUnstructured(res.GetDesired().GetResources())
+		for _, name := range selectResourceNames(objects, "*") {
+			p := fieldpath.Pave(objects[name].UnstructuredContent())
+			for _, fp := range paths {
+				if _, err := p.GetValue(fp); err != nil {
+					failed = append(failed, fmt.Sprintf("%s: missing field %s", name, fp))
+				}
+			}
+		}
+		return policyViolations(failed)
+	}
+}
+
+// ForbidFields returns a [Policy] that expects none of the desired composed
+// resources to have the fields at the given paths set, e.g.
+// "spec.forProvider.region" if regions must not be hard-coded.
+func ForbidFields(paths ...string) Policy {
+	return func(res *fnapi.RunFunctionResponse) error {
+		var failed []string
+		objects := convertResourcesMapToUnstructured(res.GetDesired().GetResources())
+		for _, name := range selectResourceNames(objects, "*") {
+			p := fieldpath.Pave(objects[name].UnstructuredContent())
+			for _, fp := range paths {
+				if v, err := p.GetValue(fp); err == nil {
+					failed = append(failed, fmt.Sprintf("%s: forbidden field %s is set to %v", name, fp, v))
+				}
+			}
+		}
+		return policyViolations(failed)
+	}
+}
+
+func policyViolations(failed []string) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New("violated by:\n  " + strings.Join(failed, "\n  "))
+}
+
+// SkipPolicies excludes the test from the registered policies with the given
+// names. The setup of the test fails if any of them is not registered.
+func SkipPolicies(names ...string) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		if tc.skipPolicies == nil {
+			tc.skipPolicies = map[string]bool{}
+		}
+		for _, n := range names {
+			tc.skipPolicies[n] = true
+		}
+		tc.setups = append(tc.setups, func() error {
+			return unknownPolicies(names)
+		})
+	}
+}
+
+// unknownPolicies returns an error that lists all names that no policy is
+// registered under.
+func unknownPolicies(names []string) error {
+	policies.mu.RLock()
+	defer policies.mu.RUnlock()
+	var unknown []string
+	for _, n := range names {
+		if _, exists := policies.policies[n]; !exists {
+			unknown = append(unknown, n)
+		}
+	}
+	if len(unknown) > 0 {
+		return errors.Errorf("cannot skip unknown policies %q", unknown)
+	}
+	return nil
+}
+
+// SkipAllPolicies excludes the test from all registered policies.
+func SkipAllPolicies() TestFunctionOpt {
+	return func(tc *FunctionTest) { tc.skipAllPolicies = true }
+}
+
+// evaluatePolicies evaluates all registered policies that the test did not
+// opt out of against the response.
+func (tc *FunctionTest) evaluatePolicies(res *fnapi.RunFunctionResponse, err error) []Assertion {
+	if err != nil || tc.skipAllPolicies {
+		return nil
+	}
+	policies.mu.RLock()
+	names := make([]string, 0, len(policies.policies))
+	for n := range policies.policies {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	active := make([]Policy, 0, len(names))
+	activeNames := make([]string, 0, len(names))
+	for _, n := range names {
+		if tc.skipPolicies[n] {
+			continue
+		}
+		active = append(active, policies.policies[n])
+		activeNames = append(activeNames, n)
+	}
+	policies.mu.RUnlock()
+
+	assertions := make([]Assertion, len(active))
+	for i, p := range active {
+		assertions[i] = passedAssertion(fmt.Sprintf("Policy %q", activeNames[i]))
+		if err := p(res); err != nil {
+			assertions[i] = failedAssertion(assertions[i].Name, err.Error(), "")
+		}
+	}
+	return assertions
+}
@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPolicies(t *testing.T) {
+	RegisterPolicy("test-labels", RequireLabels("team"))
+	t.Cleanup(func() { UnregisterPolicy("test-labels") })
+	fn := desiredResourcesFunction(t, map[string]map[string]any{
+		"bucket": {"apiVersion": "s3.aws.upbound.io/v1beta1", "kind": "Bucket"},
+	})
+	opts := []TestFunctionOpt{AllowExtraDesiredResources()}
+
+	cases := map[string]struct {
+		opts        []TestFunctionOpt
+		wantFailed  []string
+		wantSetup   string
+		wantMessage string
+	}{
+		"Violated": {
+			wantFailed:  []string{`Policy "test-labels"`},
+			wantMessage: "violated by:\n  bucket: missing label \"team\"",
+		},
+		"Skipped": {
+			opts: []TestFunctionOpt{SkipPolicies("test-labels")},
+		},
+		"AllSkipped": {
+			opts: []TestFunctionOpt{SkipAllPolicies()},
+		},
+		"UnknownSkipped": {
+			opts:       []TestFunctionOpt{SkipPolicies("test-labels", "test-typo")},
+			wantFailed: []string{"Setup"},
+			wantSetup:  `cannot skip unknown policies ["test-typo"]`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assertions := EvaluateFunction(t.Name(), fn, slices.Concat(opts, c.opts)...)
+			if got := failedAssertions(assertions); !slices.Equal(got, c.wantFailed) {
+				t.Errorf("failed assertions: want %q, got %q", c.wantFailed, got)
+			}
+			if c.wantSetup != "" && !strings.Contains(assertions[0].Message, c.wantSetup) {
+				t.Errorf("setup message: want %q in %q", c.wantSetup, assertions[0].Message)
+			}
+			for _, a := range assertions {
+				if c.wantMessage != "" && a.Status == AssertionFailed && a.Message != c.wantMessage {
+					t.Errorf("%s message: want %q, got %q", a.Name, c.wantMessage, a.Message)
+				}
+			}
+		})
+	}
+}
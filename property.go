@@ -0,0 +1,511 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/fuzz"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/schema"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+const (
+	// EnvPropertySeed is the environment variable that sets the seed of
+	// [ForAll] to reproduce a failure.
+	EnvPropertySeed = "FNTEST_PROPERTY_SEED"
+
+	// propertyRuns is the number of requests [ForAll] generates. It is
+	// reduced to propertyRunsShort in short mode.
+	propertyRuns      = 100
+	propertyRunsShort = 10
+
+	// maxPropertyDiscards is the number of generated requests [ForAll]
+	// discards because they are invalid before it gives up.
+	maxPropertyDiscards = 1000
+
+	// maxShrinkAttempts is the number of smaller requests [ForAll] tries when
+	// shrinking a counterexample.
+	maxShrinkAttempts = 2000
+
+	// propertyDataSize is the number of random bytes all random choices of a
+	// generated request are drawn from. Exhausted bytes yield minimal choices.
+	propertyDataSize = 4096
+)
+
+// errDiscard is returned by generators if they generated an invalid object.
+var errDiscard = errors.New("generated object is invalid")
+
+// Generator generates random objects of the request for property based tests.
+// See [ForAll].
+type Generator struct {
+	generate func(src *fuzz.Source, req *generatedRequest) error
+}
+
+// generatedRequest are the objects generated for a request.
+type generatedRequest struct {
+	composite          *unstructured.Unstructured
+	resources          map[string]*unstructured.Unstructured
+	environmentConfigs []*unstructured.Unstructured
+}
+
+// GenerateXR generates observed composites from the schemas of the
+// CompositeResourceDefinitions in the multi-document xrdYAML. The version and
+// the values of the composite are random, but valid against the schema after
+// its defaults are applied.
+func GenerateXR(xrdYAML []byte) Generator {
+	v := mustSchemaValidator(xrdYAML, kindXRD)
+	return Generator{generate: func(src *fuzz.Source, req *generatedRequest) error {
+		u, err := generateObject(v, src)
+		if err != nil {
+			return err
+		}
+		u.SetName("xr")
+		req.composite = u
+		return nil
+	}}
+}
+
+// GenerateComposed generates an observed composed resource with the given
+// name from the schemas of the CustomResourceDefinitions in the
+// multi-document crdYAML. The kind, version and the values of the resource
+// are random, but valid against the schema after its defaults are applied.
+func GenerateComposed(name string, crdYAML []byte) Generator {
+	v := mustSchemaValidator(crdYAML, kindCRD)
+	return Generator{generate: func(src *fuzz.Source, req *generatedRequest) error {
+		u, err := generateObject(v, src)
+		if err != nil {
+			return err
+		}
+		u.SetName(name)
+		req.resources[name] = u
+		return nil
+	}}
+}
+
+// GenerateEnvironmentConfigs generates EnvironmentConfigs from the ones in the
+// multi-document templateYAML. Fields of their data are removed or set to
+// random values of the same type.
+//
+// Experimental: Environments are a Crossplane alpha feature and are prone to
+// change in the future. This applies to this functions as well.
+func GenerateEnvironmentConfigs(templateYAML []byte) Generator {
+	templates, err := yaml.UnmarshalObjects[*unstructured.Unstructured](templateYAML)
+	if err != nil {
+		panic(err.Error())
+	}
+	return Generator{generate: func(src *fuzz.Source, req *generatedRequest) error {
+		for _, tpl := range templates {
+			u := tpl.DeepCopy()
+			if data, ok := u.Object["data"].(map[string]interface{}); ok {
+				fuzz.Mutate(data, nil, src)
+			}
+			req.environmentConfigs = append(req.environmentConfigs, u)
+		}
+		return nil
+	}}
+}
+
+// CombineGenerators returns a [Generator] that runs all gens.
+func CombineGenerators(gens ...Generator) Generator {
+	return Generator{generate: func(src *fuzz.Source, req *generatedRequest) error {
+		for _, g := range gens {
+			if err := g.generate(src, req); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+func mustSchemaValidator(rawYAML []byte, kind string) *schema.Validator {
+	objects, err := yaml.UnmarshalObjects[*unstructured.Unstructured](rawYAML)
+	if err != nil {
+		panic(err.Error())
+	}
+	v := schema.NewValidator()
+	for _, o := range objects {
+		if o.GetKind() != kind {
+			panic(fmt.Sprintf("object %s is not a %s", o.GetName(), kind))
+		}
+		if err := v.AddObject(o); err != nil {
+			panic(err.Error())
+		}
+	}
+	if len(v.ServedKinds()) == 0 {
+		panic(fmt.Sprintf("no %s defines a served version", kind))
+	}
+	return v
+}
+
+// generateObject generates an object of a random served kind of v. It
+// returns errDiscard if the object is invalid.
+func generateObject(v *schema.Validator, src *fuzz.Source) (*unstructured.Unstructured, error) {
+	kinds := v.ServedKinds()
+	gvk := kinds[src.Intn(len(kinds))]
+
+	obj, ok := fuzz.Generate(v.Structural(gvk), src).(map[string]any)
+	if !ok {
+		obj = map[string]any{}
+	}
+	u := &unstructured.Unstructured{Object: obj}
+	delete(u.Object, "metadata")
+	u.SetGroupVersionKind(gvk)
+	v.Default(u)
+
+	errs, err := v.Validate(u, schema.ValidateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errDiscard
+	}
+	return u, nil
+}
+
+// ForAll runs fn with requests built from opts and random objects of gen and
+// expects property to hold for every response. A property holds if it returns
+// true and no assertion of the [Response] passed to it fails.
+//
+// The property fails as well if the function panics or returns an error.
+// Generated objects that are invalid against their schemas are discarded.
+//
+// If the property fails, the generated objects are shrunk to a minimal
+// counterexample, which is printed as YAML fixtures that can be passed to
+// [WithObservedCompositeYAML], [WithObservedResourcesYAML] and
+// [WithEnvironmentFromConfigsYAML] in a regression test. The failure contains
+// the seed to reproduce it with the environment variable [EnvPropertySeed].
+func ForAll(t *testing.T, fn fnapi.FunctionRunnerServiceServer, gen Generator, property func(res *Response) bool, opts ...TestFunctionOpt) {
+	t.Helper()
+
+	seed := rand.Uint64() //nolint:gosec // No need for cryptographic randomness.
+	if s := os.Getenv(EnvPropertySeed); s != "" {
+		var err error
+		if seed, err = strconv.ParseUint(s, 10, 64); err != nil {
+			t.Fatal(errors.Wrapf(err, "invalid %s", EnvPropertySeed))
+		}
+	}
+	rnd := rand.New(rand.NewPCG(seed, seed)) //nolint:gosec // No need for cryptographic randomness.
+
+	runs := propertyRuns
+	if testing.Short() {
+		runs = propertyRunsShort
+	}
+	p := &propertyRun{t: t, fn: fn, gen: gen, property: property, opts: opts}
+	for run, discarded := 0, 0; run < runs; {
+		data := make([]byte, propertyDataSize)
+		for i := range data {
+			data[i] = byte(rnd.Uint32())
+		}
+		c, err := p.check(data)
+		switch {
+		case errors.Is(err, errDiscard):
+			discarded++
+			if discarded > maxPropertyDiscards {
+				t.Fatalf("Gave up after discarding %d invalid or skipped generated requests; %d requests passed", discarded, run)
+			}
+			continue
+		case err != nil:
+			t.Fatal(err)
+		}
+		run++
+		if c == nil {
+			continue
+		}
+
+		minimal, steps := p.shrink(c)
+		t.Errorf("Property failed after %d runs (seed %d, %s=%d to reproduce), shrunk in %d steps:\n%s\n\nMinimal counterexample:\n\n%s",
+			run, seed, EnvPropertySeed, seed, steps, strings.Join(minimal.failures, "\n"), minimal.fixture())
+		return
+	}
+}
+
+// propertyRun runs a property against requests that are generated from
+// random bytes.
+type propertyRun struct {
+	t        testing.TB
+	fn       fnapi.FunctionRunnerServiceServer
+	gen      Generator
+	property func(res *Response) bool
+	opts     []TestFunctionOpt
+}
+
+// counterexample is a generated request for which a property failed.
+type counterexample struct {
+	data     []byte
+	req      *generatedRequest
+	failures []string
+}
+
+// check generates a request from data and evaluates the property for it. It
+// returns nil if the property holds.
+func (p *propertyRun) check(data []byte) (*counterexample, error) {
+	src := fuzz.NewSource(data)
+	gr := &generatedRequest{resources: map[string]*unstructured.Unstructured{}}
+	if err := p.gen.generate(src, gr); err != nil {
+		return nil, err
+	}
+	// Only the bytes used for choices are relevant for shrinking.
+	data = data[:src.Used()]
+
+	tc := generateTc(p.fn)
+	for _, o := range p.opts {
+		o(tc)
+	}
+	gr.apply(tc)
+	if err := tc.setup(); err != nil {
+		return nil, errors.Wrap(err, "cannot set up test")
+	}
+
+	res, err := tc.runFunction(proto.Clone(tc.req).(*fnapi.RunFunctionRequest)) //nolint:forcetypeassert // Clone returns the same type.
+	if err != nil {
+		return &counterexample{data: data, req: gr, failures: []string{err.Error()}}, nil
+	}
+	rt := &propertyT{TB: p.t}
+	ok := rt.evaluate(func() bool { return p.property(NewResponse(rt, res)) })
+	if rt.skipped {
+		return nil, errDiscard
+	}
+	if !ok || len(rt.failures) > 0 {
+		failures := rt.failures
+		if len(failures) == 0 {
+			failures = []string{"property returned false"}
+		}
+		return &counterexample{data: data, req: gr, failures: failures}, nil
+	}
+	return nil, nil
+}
+
+// shrink minimizes the random bytes of c as long as the property still fails
+// and returns the minimal counterexample and the number of successful shrink
+// steps. Removing and zeroing bytes leads to fewer and smaller choices.
+func (p *propertyRun) shrink(c *counterexample) (*counterexample, int) {
+	steps, attempts := 0, 0
+	try := func(data []byte) bool {
+		if attempts >= maxShrinkAttempts {
+			return false
+		}
+		attempts++
+		smaller, err := p.check(data)
+		if err != nil || smaller == nil {
+			return false
+		}
+		c = smaller
+		steps++
+		return true
+	}
+
+	for improved := true; improved && attempts < maxShrinkAttempts; {
+		improved = false
+		for size := max(len(c.data)/2, 1); size >= 1; size /= 2 {
+			for i := 0; i+size <= len(c.data); {
+				if try(append(append([]byte{}, c.data[:i]...), c.data[i+size:]...)) {
+					improved = true
+					continue
+				}
+				i += size
+			}
+		}
+		for i := 0; i < len(c.data); i++ {
+			for _, b := range []byte{0, c.data[i] / 2, c.data[i] - 1} {
+				if i >= len(c.data) || b >= c.data[i] {
+					continue
+				}
+				data := append([]byte{}, c.data...)
+				data[i] = b
+				if try(data) {
+					improved = true
+					break
+				}
+			}
+		}
+	}
+	return c, steps
+}
+
+// apply adds the generated objects to the request of tc.
+func (g *generatedRequest) apply(tc *FunctionTest) {
+	if g.composite != nil {
+		tc.req.Observed.Composite = &fnapi.Resource{Resource: mustObjectAsStruct(g.composite)}
+	}
+	for name, u := range g.resources {
+		tc.req.Observed.Resources[name] = &fnapi.Resource{Resource: mustObjectAsStruct(u)}
+	}
+	if len(g.environmentConfigs) > 0 {
+		raw, err := yaml.MarshalObjects(g.environmentConfigs)
+		if err != nil {
+			panic(err.Error())
+		}
+		WithEnvironmentFromConfigsYAML(raw)(tc)
+	}
+}
+
+// fixture returns the generated objects as YAML fixtures.
+func (c *counterexample) fixture() string {
+	b := &strings.Builder{}
+	writeFixture := func(desc string, objects []*unstructured.Unstructured) {
+		if len(objects) == 0 {
+			return
+		}
+		raw, err := yaml.MarshalObjects(objects)
+		if err != nil {
+			fmt.Fprintf(b, "# %s: %s\n", desc, err)
+			return
+		}
+		fmt.Fprintf(b, "# %s\n%s\n", desc, raw)
+	}
+
+	if c.req.composite != nil {
+		writeFixture("observed composite (WithObservedCompositeYAML)", []*unstructured.Unstructured{c.req.composite})
+	}
+	resources := make([]*unstructured.Unstructured, 0, len(c.req.resources))
+	for _, name := range maps.SortedKeys(c.req.resources) {
+		u := c.req.resources[name].DeepCopy()
+		u.SetAnnotations(mergeStringMaps(u.GetAnnotations(), map[string]string{AnnotationKeyResourceName: name}))
+		resources = append(resources, u)
+	}
+	writeFixture("observed resources (WithObservedResourcesYAML)", resources)
+	writeFixture("environment configs (WithEnvironmentFromConfigsYAML)", c.req.environmentConfigs)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func mergeStringMaps(a, b map[string]string) map[string]string {
+	out := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+// propertyT records the failed assertions of a property instead of failing
+// the test. Skipping a property discards the generated request. Cleanups and
+// temporary directories are scoped to the evaluation of the property for one
+// generated request. Methods that change the process, like Setenv and Chdir,
+// fail the property, as the evaluations of a property are not isolated.
+type propertyT struct {
+	testing.TB
+	failures []string
+	skipped  bool
+	cleanups []func()
+}
+
+// propertyFailNow is the panic value that stops the evaluation of a property
+// after a fatal assertion.
+type propertyFailNow struct{}
+
+// propertySkipNow is the panic value that stops the evaluation of a property
+// that was skipped.
+type propertySkipNow struct{}
+
+// evaluate runs property, recovers from fatal assertions and skips and runs
+// the registered cleanups afterwards.
+func (t *propertyT) evaluate(property func() bool) (ok bool) {
+	defer func() {
+		for i := len(t.cleanups) - 1; i >= 0; i-- {
+			t.cleanups[i]()
+		}
+		t.cleanups = nil
+	}()
+	defer func() {
+		if v := recover(); v != nil {
+			switch v.(type) {
+			case propertyFailNow:
+			case propertySkipNow:
+				t.skipped = true
+			default:
+				panic(v)
+			}
+			ok = false
+		}
+	}()
+	return property()
+}
+
+func (t *propertyT) Helper() {}
+
+func (t *propertyT) Error(args ...any) {
+	t.failures = append(t.failures, fmt.Sprint(args...))
+}
+
+func (t *propertyT) Errorf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *propertyT) Fail() {
+	t.failures = append(t.failures, "property failed")
+}
+
+func (t *propertyT) FailNow() {
+	t.Fail()
+	panic(propertyFailNow{})
+}
+
+func (t *propertyT) Failed() bool {
+	return len(t.failures) > 0
+}
+
+func (t *propertyT) Fatal(args ...any) {
+	t.Error(args...)
+	panic(propertyFailNow{})
+}
+
+func (t *propertyT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	panic(propertyFailNow{})
+}
+
+func (t *propertyT) Log(...any) {}
+
+func (t *propertyT) Logf(string, ...any) {}
+
+func (t *propertyT) Skip(...any) {
+	t.SkipNow()
+}
+
+func (t *propertyT) Skipf(string, ...any) {
+	t.SkipNow()
+}
+
+func (t *propertyT) SkipNow() {
+	panic(propertySkipNow{})
+}
+
+func (t *propertyT) Skipped() bool {
+	return t.skipped
+}
+
+func (t *propertyT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *propertyT) TempDir() string {
+	dir, err := os.MkdirTemp("", "property-")
+	if err != nil {
+		t.Fatalf("TempDir: %s", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func (t *propertyT) Setenv(key, _ string) {
+	t.Fatalf("Setenv(%q) is not supported in a property of ForAll, set the environment before calling ForAll", key)
+}
+
+func (t *propertyT) Chdir(dir string) {
+	t.Fatalf("Chdir(%q) is not supported in a property of ForAll, change the directory before calling ForAll", dir)
+}
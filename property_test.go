@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+)
+
+var testSizeXRD = []byte(`
+apiVersion: apiextensions.crossplane.io/v1
+kind: CompositeResourceDefinition
+metadata:
+  name: xdisks.example.org
+spec:
+  group: example.org
+  names:
+    kind: XDisk
+    plural: xdisks
+  versions:
+    - name: v1
+      served: true
+      referenceable: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [size]
+              properties:
+                size:
+                  type: integer
+                  minimum: 0
+                  maximum: 100
+`)
+
+// echoCompositeFunction returns the observed composite as desired composite.
+var echoCompositeFunction = &testFunction{run: func(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+	return &fnapi.RunFunctionResponse{Desired: &fnapi.State{Composite: req.GetObserved().GetComposite()}}, nil
+}}
+
+// desiredSize returns spec.size of the desired composite of res.
+func desiredSize(res *Response) (int64, bool) {
+	spec, ok := res.Raw().GetDesired().GetComposite().GetResource().AsMap()["spec"].(map[string]any)
+	if !ok {
+		return 0, false
+	}
+	size, ok := spec["size"].(float64)
+	return int64(size), ok
+}
+
+// randomData returns propertyDataSize random bytes.
+func randomData(rnd *rand.Rand) []byte {
+	data := make([]byte, propertyDataSize)
+	for i := range data {
+		data[i] = byte(rnd.Uint32())
+	}
+	return data
+}
+
+func TestForAllHolds(t *testing.T) {
+	ForAll(t, echoCompositeFunction, GenerateXR(testSizeXRD), func(res *Response) bool {
+		size, ok := desiredSize(res)
+		return ok && size >= 0 && size <= 100
+	})
+}
+
+func TestPropertyShrink(t *testing.T) {
+	p := &propertyRun{t: t, fn: echoCompositeFunction, gen: GenerateXR(testSizeXRD), property: func(res *Response) bool {
+		size, _ := desiredSize(res)
+		return size <= 5
+	}}
+
+	rnd := rand.New(rand.NewPCG(1, 1)) //nolint:gosec // No need for cryptographic randomness.
+	var c *counterexample
+	for c == nil {
+		var err error
+		c, err = p.check(randomData(rnd))
+		if err != nil && !errors.Is(err, errDiscard) {
+			t.Fatal(err)
+		}
+	}
+
+	minimal, steps := p.shrink(c)
+	if steps == 0 {
+		t.Error("shrink(...): no shrink steps")
+	}
+	if len(minimal.data) > len(c.data) {
+		t.Errorf("shrink(...): %d bytes, want at most %d", len(minimal.data), len(c.data))
+	}
+	if got := minimal.req.composite.Object["spec"].(map[string]any)["size"]; got != int64(6) { //nolint:forcetypeassert // The generated composite has a spec.
+		t.Errorf("shrink(...): size %v, want 6", got)
+	}
+	if fixture := minimal.fixture(); !strings.Contains(fixture, "size: 6") || !strings.Contains(fixture, "WithObservedCompositeYAML") {
+		t.Errorf("fixture(): got\n%s", fixture)
+	}
+}
+
+func TestPropertyT(t *testing.T) {
+	cases := map[string]struct {
+		property    func(t testing.TB) bool
+		wantErr     error
+		wantFailure string
+	}{
+		"Holds": {
+			property: func(testing.TB) bool { return true },
+		},
+		"ReturnsFalse": {
+			property:    func(testing.TB) bool { return false },
+			wantFailure: "property returned false",
+		},
+		"Fatal": {
+			property: func(t testing.TB) bool {
+				t.Fatal("boom")
+				return true
+			},
+			wantFailure: "boom",
+		},
+		"Skip": {
+			property: func(t testing.TB) bool {
+				t.Skip("not applicable")
+				return false
+			},
+			wantErr: errDiscard,
+		},
+		"Setenv": {
+			property: func(t testing.TB) bool {
+				t.Setenv("KEY", "value")
+				return true
+			},
+			wantFailure: `Setenv("KEY") is not supported in a property`,
+		},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := &propertyRun{t: t, fn: echoCompositeFunction, gen: GenerateXR(testSizeXRD), property: func(res *Response) bool {
+				return tt.property(res.T())
+			}}
+			c, err := p.check(randomData(rand.New(rand.NewPCG(1, 1)))) //nolint:gosec // No need for cryptographic randomness.
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("check(...): error %v, want %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.wantFailure == "" && c != nil:
+				t.Errorf("check(...): unexpected failures %q", c.failures)
+			case tt.wantFailure != "" && (c == nil || !strings.Contains(strings.Join(c.failures, "\n"), tt.wantFailure)):
+				t.Errorf("check(...): got %v, want failure %q", c, tt.wantFailure)
+			}
+			if t.Skipped() || t.Failed() {
+				t.Error("property acted on the outer test")
+			}
+		})
+	}
+}
+
+func TestPropertyTCleanup(t *testing.T) {
+	var dirs []string
+	cleanups := 0
+	p := &propertyRun{t: t, fn: echoCompositeFunction, gen: GenerateXR(testSizeXRD), property: func(res *Response) bool {
+		res.T().Cleanup(func() { cleanups++ })
+		dir := res.T().TempDir()
+		dirs = append(dirs, dir)
+		_, err := os.Stat(dir)
+		return err == nil && cleanups == len(dirs)-1
+	}}
+
+	rnd := rand.New(rand.NewPCG(1, 1)) //nolint:gosec // No need for cryptographic randomness.
+	for range 3 {
+		c, err := p.check(randomData(rnd))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != nil {
+			t.Fatalf("check(...): %q", c.failures)
+		}
+	}
+	if cleanups != 3 {
+		t.Errorf("cleanups: got %d, want 3", cleanups)
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("temporary directory %s was not removed", dir)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/diff"
+)
+
+// AssertionStatus is the outcome of an assertion.
+type AssertionStatus string
+
+const (
+	// AssertionPassed is the status of a met expectation.
+	AssertionPassed AssertionStatus = "passed"
+	// AssertionFailed is the status of a violated expectation.
+	AssertionFailed AssertionStatus = "failed"
+	// AssertionWarning is the status of a finding that does not fail the
+	// test, e.g. an allowed extra resource.
+	AssertionWarning AssertionStatus = "warning"
+)
+
+// Assertion is the result of a single expectation of a function test, e.g.
+// the comparison of one desired composed resource.
+type Assertion struct {
+	// Name identifies the asserted part of the response, e.g.
+	// res.Desired.Composite.
+	Name    string          `json:"name"`
+	Status  AssertionStatus `json:"status"`
+	Message string          `json:"message,omitempty"`
+	Diff    string          `json:"diff,omitempty"`
+}
+
+func passedAssertion(name string) Assertion {
+	return Assertion{Name: name, Status: AssertionPassed}
+}
+
+func failedAssertion(name, message, diff string) Assertion {
+	return Assertion{Name: name, Status: AssertionFailed, Message: message, Diff: diff}
+}
+
+// String formats the assertion the way it is reported to the test.
+func (a Assertion) String() string {
+	s := a.Name
+	if a.Message != "" {
+		s += ": " + a.Message
+	}
+	if a.Diff != "" {
+		s += "\n" + a.Diff
+	}
+	return s
+}
+
+// reportAssertions reports failed assertions as errors and warnings as logs
+// to t.
+func reportAssertions(t testing.TB, assertions []Assertion) {
+	t.Helper()
+	color := diffColor()
+	for _, a := range assertions {
+		if color {
+			a.Diff = diff.Colorize(a.Diff)
+		}
+		switch a.Status {
+		case AssertionFailed:
+			t.Error(a.String())
+		case AssertionWarning:
+			t.Log("Warning: " + a.String())
+		case AssertionPassed:
+		}
+	}
+}
+
+var report = &reportRecorder{}
+
+// reportRecorder records the assertions of all function tests of a package.
+type reportRecorder struct {
+	mu      sync.Mutex
+	enabled bool
+	tests   []testReport
+}
+
+// testReport contains the assertions of a single [TestFunction] call.
+type testReport struct {
+	Test string `json:"test"`
+	// Elapsed is the duration of the call in seconds.
+	Elapsed    float64     `json:"elapsed"`
+	Assertions []Assertion `json:"assertions"`
+}
+
+func (r *reportRecorder) record(tr testReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enabled {
+		r.tests = append(r.tests, tr)
+	}
+}
+
+// EnableReport starts recording the assertions of all subsequent
+// [TestFunction] calls for [WriteJUnitReport] and [WriteJSONReport]. It is
+// typically called from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		fntesting.EnableReport()
+//		code := m.Run()
+//		if err := fntesting.WriteJUnitReport("function-tests.xml"); err != nil {
+//			fmt.Println(err)
+//		}
+//		os.Exit(code)
+//	}
+func EnableReport() {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.enabled = true
+}
+
+// jsonReport is the format of [WriteJSONReport].
+type jsonReport struct {
+	Tests    int          `json:"tests"`
+	Failures int          `json:"failures"`
+	Warnings int          `json:"warnings"`
+	Results  []testReport `json:"results"`
+}
+
+// WriteJSONReport writes all assertions recorded since [EnableReport] was
+// called as JSON to path. The report contains the number of recorded
+// [TestFunction] calls, failed assertions and warnings and, per call, the name
+// of the test and the status, message and diff of every assertion.
+func WriteJSONReport(path string) error {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	out := jsonReport{Results: report.tests}
+	for _, tr := range report.tests {
+		out.Tests++
+		for _, a := range tr.Assertions {
+			switch a.Status {
+			case AssertionFailed:
+				out.Failures++
+			case AssertionWarning:
+				out.Warnings++
+			case AssertionPassed:
+			}
+		}
+	}
+	raw, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal report")
+	}
+	return errors.Wrap(os.WriteFile(path, raw, 0o644), "cannot write report") //nolint:gosec // Reports are meant to be read.
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes all assertions recorded since [EnableReport] was
+// called as JUnit XML to path. Every [TestFunction] call is a test suite
+// named after the test and every assertion a test case of it, so failures
+// are shown per resource. Warnings are written to the output of their test
+// case.
+func WriteJUnitReport(path string) error {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	out := junitTestSuites{}
+	for _, tr := range report.tests {
+		suite := junitTestSuite{
+			Name: tr.Test,
+			Time: fmt.Sprintf("%.3f", tr.Elapsed),
+		}
+		for _, a := range tr.Assertions {
+			tc := junitTestCase{Name: a.Name, ClassName: tr.Test}
+			switch a.Status {
+			case AssertionFailed:
+				tc.Failure = &junitFailure{Message: a.Message, Text: a.Diff}
+				suite.Failures++
+			case AssertionWarning:
+				tc.SystemOut = strings.TrimSpace("Warning: " + a.Message + "\n" + a.Diff)
+			case AssertionPassed:
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		out.Tests += suite.Tests
+		out.Failures += suite.Failures
+		out.Suites = append(out.Suites, suite)
+	}
+	raw, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal report")
+	}
+	raw = append([]byte(xml.Header), raw...)
+	return errors.Wrap(os.WriteFile(path, raw, 0o644), "cannot write report") //nolint:gosec // Reports are meant to be read.
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/google/go-cmp/cmp"
+)
+
+// enableTestReport records the assertions of the test in a new report.
+func enableTestReport(t *testing.T) {
+	t.Helper()
+	previous := report
+	report = &reportRecorder{enabled: true}
+	t.Cleanup(func() { report = previous })
+}
+
+// runReportedTests runs a test that passes, fails, fails to set up, panics
+// and panics as expected and returns the summary of the failures the report
+// should contain per test, formatted as "assertion: message".
+func runReportedTests(t *testing.T) map[string][]string {
+	t.Helper()
+	fn := desiredResourcesFunction(t, map[string]map[string]any{
+		"bucket": {"apiVersion": "example.org/v1", "kind": "Bucket", "spec": map[string]any{"region": "eu"}},
+	})
+	panicking := &testFunction{run: func(*fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+		panic("boom")
+	}}
+	cases := []struct {
+		name string
+		fn   *testFunction
+		opts []TestFunctionOpt
+	}{
+		{name: "Passed", fn: fn, opts: []TestFunctionOpt{ExpectDesiredResourceYAML("bucket", []byte(`{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu}}`))}},
+		{name: "Failed", fn: fn, opts: []TestFunctionOpt{ExpectDesiredResourceYAML("bucket", []byte(`{apiVersion: example.org/v1, kind: Bucket, spec: {region: us}}`))}},
+		{name: "Setup", fn: fn, opts: []TestFunctionOpt{SkipPolicies("test-unknown")}},
+		{name: "Panic", fn: panicking},
+		{name: "ExpectedPanic", fn: panicking, opts: []TestFunctionOpt{ExpectPanic(PanicMessageContains("boom"))}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("TMPDIR", t.TempDir())
+			ft := &fakeTB{TB: t}
+			ft.run(func() { runFunctionTest(ft, c.fn, c.opts...) })
+		})
+	}
+	return map[string][]string{
+		t.Name() + "/Passed":        nil,
+		t.Name() + "/Failed":        {`res.Desired.Resources["bucket"]: -want +got`},
+		t.Name() + "/Setup":         {`Setup: cannot set up test: cannot skip unknown policies ["test-unknown"]`},
+		t.Name() + "/Panic":         {"Panic: function panicked: boom"},
+		t.Name() + "/ExpectedPanic": nil,
+	}
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	enableTestReport(t)
+	want := runReportedTests(t)
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := WriteJSONReport(path); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := jsonReport{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Tests != len(want) || got.Failures != 3 || got.Warnings != 0 {
+		t.Errorf("want %d tests, 3 failures and 0 warnings, got %d, %d and %d", len(want), got.Tests, got.Failures, got.Warnings)
+	}
+	failures := map[string][]string{}
+	for _, tr := range got.Results {
+		if len(tr.Assertions) == 0 {
+			t.Errorf("%s: want assertions, got none", tr.Test)
+		}
+		failures[tr.Test] = nil
+		for _, a := range tr.Assertions {
+			if a.Status == AssertionFailed {
+				failures[tr.Test] = append(failures[tr.Test], a.Name+": "+a.Message)
+			}
+		}
+	}
+	if diff := cmp.Diff(want, failures); diff != "" {
+		t.Errorf("failures: -want +got\n%s", diff)
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	enableTestReport(t)
+	want := runReportedTests(t)
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	if err := WriteJUnitReport(path); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(raw), xml.Header) {
+		t.Errorf("want the XML header, got:\n%s", raw)
+	}
+	got := junitTestSuites{}
+	if err := xml.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Suites) != len(want) || got.Failures != 3 {
+		t.Errorf("want %d suites and 3 failures, got %d and %d", len(want), len(got.Suites), got.Failures)
+	}
+	failures := map[string][]string{}
+	tests := 0
+	for _, s := range got.Suites {
+		if s.Tests != len(s.Cases) || s.Tests == 0 {
+			t.Errorf("%s: want the number of its %d test cases, got %d", s.Name, len(s.Cases), s.Tests)
+		}
+		tests += s.Tests
+		failures[s.Name] = nil
+		for _, c := range s.Cases {
+			if c.ClassName != s.Name {
+				t.Errorf("%s: want class name %q, got %q", c.Name, s.Name, c.ClassName)
+			}
+			if c.Failure != nil {
+				failures[s.Name] = append(failures[s.Name], c.Name+": "+c.Failure.Message)
+			}
+		}
+	}
+	if got.Tests != tests {
+		t.Errorf("want %d tests, got %d", tests, got.Tests)
+	}
+	if diff := cmp.Diff(want, failures); diff != "" {
+		t.Errorf("failures: -want +got\n%s", diff)
+	}
+}
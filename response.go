@@ -0,0 +1,405 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	resourceNameComposite = "composite"
+)
+
+// Response wraps a [fnapi.RunFunctionResponse] and provides fluent assertions
+// on its content. Failed assertions are reported through the [testing.TB]
+// passed to them instead of panicking.
+type Response struct {
+	t   testing.TB
+	res *fnapi.RunFunctionResponse
+}
+
+// NewResponse wraps res in a [Response]. The given t is used to report
+// failures of functions that don't accept a [testing.TB] themselves, such as
+// [Resource] and [Composite].
+func NewResponse(t testing.TB, res *fnapi.RunFunctionResponse) *Response {
+	if res == nil {
+		res = &fnapi.RunFunctionResponse{}
+	}
+	return &Response{t: t, res: res}
+}
+
+// TestFunctionGetResponse is the same as [TestFunctionGetResult] but wraps the
+// result in a [Response].
+func TestFunctionGetResponse(t *testing.T, fn fnapi.FunctionRunnerServiceServer, opts ...TestFunctionOpt) *Response {
+	t.Helper()
+	return NewResponse(t, TestFunctionGetResult(t, fn, opts...))
+}
+
+// T returns the [testing.TB] the response was created with. Assertions in a
+// property of [ForAll] must report to it, so failures are recorded for the
+// generated request instead of failing the test immediately.
+func (r *Response) T() testing.TB {
+	return r.t
+}
+
+// Raw returns the wrapped [fnapi.RunFunctionResponse].
+func (r *Response) Raw() *fnapi.RunFunctionResponse {
+	return r.res
+}
+
+// Resource returns the assertions for the desired composed resource with the
+// given name. A malformed resource fails every assertion on it.
+func (r *Response) Resource(name string) *ResourceAssertion {
+	return newResourceAssertion(name, r.res.GetDesired().GetResources()[name])
+}
+
+// Composite returns the assertions for the desired composite resource. A
+// malformed composite fails every assertion on it.
+func (r *Response) Composite() *ResourceAssertion {
+	return newResourceAssertion(resourceNameComposite, r.res.GetDesired().GetComposite())
+}
+
+func newResourceAssertion(name string, res *fnapi.Resource) *ResourceAssertion {
+	a := &ResourceAssertion{name: name, res: res}
+	a.object, a.err = resourceAsUnstructured(res)
+	return a
+}
+
+// Results returns the assertions for the results of the function.
+func (r *Response) Results() *ResultsAssertion {
+	return &ResultsAssertion{results: r.res.GetResults()}
+}
+
+// Resource decodes the desired composed resource with the given name into a
+// new T. It fails the test if the resource does not exist or cannot be
+// decoded.
+func Resource[T any](r *Response, name string) *T {
+	r.t.Helper()
+	return decodeResource[T](r.t, r.Resource(name))
+}
+
+// Composite decodes the desired composite resource into a new T. It fails the
+// test if the desired composite does not exist or cannot be decoded.
+func Composite[T any](r *Response) *T {
+	r.t.Helper()
+	return decodeResource[T](r.t, r.Composite())
+}
+
+func decodeResource[T any](t testing.TB, a *ResourceAssertion) *T {
+	t.Helper()
+	if a.err != nil {
+		t.Fatalf("%s: cannot convert resource: %s", a.name, a.err)
+		return nil
+	}
+	if a.object == nil {
+		t.Fatalf("%s: resource does not exist", a.name)
+		return nil
+	}
+	out := new(T)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(a.object.UnstructuredContent(), out); err != nil {
+		t.Fatalf("%s: cannot decode resource: %s", a.name, err)
+		return nil
+	}
+	return out
+}
+
+// ResourceAssertion provides assertions on a single desired resource.
+type ResourceAssertion struct {
+	name   string
+	res    *fnapi.Resource
+	object *unstructured.Unstructured
+	// err is the error of converting a malformed resource.
+	err error
+}
+
+// Object returns the resource as [unstructured.Unstructured] or nil if the
+// resource does not exist or is malformed.
+func (a *ResourceAssertion) Object() *unstructured.Unstructured {
+	return a.object
+}
+
+// Exists fails the test if the resource does not exist or is malformed.
+func (a *ResourceAssertion) Exists(t testing.TB) *ResourceAssertion {
+	t.Helper()
+	switch {
+	case a.err != nil:
+		t.Errorf("%s: cannot convert resource: %s", a.name, a.err)
+	case a.object == nil:
+		t.Errorf("%s: expected resource to exist", a.name)
+	}
+	return a
+}
+
+// NotExists fails the test if the resource exists.
+func (a *ResourceAssertion) NotExists(t testing.TB) *ResourceAssertion {
+	t.Helper()
+	if a.res != nil {
+		t.Errorf("%s: expected resource not to exist", a.name)
+	}
+	return a
+}
+
+// IsReady fails the test if the resource does not have the given ready
+// state.
+func (a *ResourceAssertion) IsReady(t testing.TB, ready fnapi.Ready) *ResourceAssertion {
+	t.Helper()
+	if !a.exists(t) {
+		return a
+	}
+	if got := a.res.GetReady(); got != ready {
+		t.Errorf("%s: expected ready state %s, got %s", a.name, ready.String(), got.String())
+	}
+	return a
+}
+
+// HasLabel fails the test if the resource does not have the label key set to
+// value.
+func (a *ResourceAssertion) HasLabel(t testing.TB, key, value string) *ResourceAssertion {
+	t.Helper()
+	if !a.exists(t) {
+		return a
+	}
+	got, exists := a.object.GetLabels()[key]
+	switch {
+	case !exists:
+		t.Errorf("%s: expected label %q to exist", a.name, key)
+	case got != value:
+		t.Errorf("%s: expected label %q to be %q, got %q", a.name, key, value, got)
+	}
+	return a
+}
+
+// HasAnnotation fails the test if the resource does not have the annotation
+// key set to value.
+func (a *ResourceAssertion) HasAnnotation(t testing.TB, key, value string) *ResourceAssertion {
+	t.Helper()
+	if !a.exists(t) {
+		return a
+	}
+	got, exists := a.object.GetAnnotations()[key]
+	switch {
+	case !exists:
+		t.Errorf("%s: expected annotation %q to exist", a.name, key)
+	case got != value:
+		t.Errorf("%s: expected annotation %q to be %q, got %q", a.name, key, value, got)
+	}
+	return a
+}
+
+// Field returns the assertions for the field at the given path, e.g.
+// "spec.forProvider.region" or "metadata.labels[team]".
+func (a *ResourceAssertion) Field(path string) *FieldAssertion {
+	fa := &FieldAssertion{resource: a.name, path: path}
+	if a.err != nil {
+		fa.err = errors.Wrap(a.err, "cannot convert resource")
+		return fa
+	}
+	if a.object == nil {
+		fa.missingResource = true
+		return fa
+	}
+	fa.value, fa.err = fieldpath.Pave(a.object.UnstructuredContent()).GetValue(path)
+	return fa
+}
+
+// Spec returns the assertions for the field at the given path below spec.
+func (a *ResourceAssertion) Spec(path string) *FieldAssertion {
+	return a.Field(joinFieldPath("spec", path))
+}
+
+// Status returns the assertions for the field at the given path below status.
+func (a *ResourceAssertion) Status(path string) *FieldAssertion {
+	return a.Field(joinFieldPath("status", path))
+}
+
+func (a *ResourceAssertion) exists(t testing.TB) bool {
+	t.Helper()
+	if a.err != nil {
+		t.Errorf("%s: cannot convert resource: %s", a.name, a.err)
+		return false
+	}
+	if a.object == nil {
+		t.Errorf("%s: resource does not exist", a.name)
+		return false
+	}
+	return true
+}
+
+func joinFieldPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	if strings.HasPrefix(path, "[") {
+		return prefix + path
+	}
+	return prefix + "." + path
+}
+
+// FieldAssertion provides assertions on a single field of a resource.
+type FieldAssertion struct {
+	resource        string
+	path            string
+	missingResource bool
+	value           any
+	err             error
+}
+
+// Value returns the value of the field and whether it exists.
+func (a *FieldAssertion) Value() (any, bool) {
+	if a.missingResource || a.err != nil {
+		return nil, false
+	}
+	return a.value, true
+}
+
+// Exists fails the test if the field does not exist.
+func (a *FieldAssertion) Exists(t testing.TB) *FieldAssertion {
+	t.Helper()
+	a.exists(t)
+	return a
+}
+
+// NotExists fails the test if the field exists.
+func (a *FieldAssertion) NotExists(t testing.TB) *FieldAssertion {
+	t.Helper()
+	switch {
+	case a.missingResource:
+		t.Errorf("%s: resource does not exist", a.resource)
+	case a.err == nil:
+		t.Errorf("%s: expected field %s not to exist, got %v", a.resource, a.path, a.value)
+	case !fieldpath.IsNotFound(a.err):
+		t.Errorf("%s: cannot get field %s: %s", a.resource, a.path, a.err)
+	}
+	return a
+}
+
+// Equals fails the test if the field does not exist or its value is not equal
+// to want. Both values are compared by their JSON representation, so numeric
+// types don't need to match exactly.
+func (a *FieldAssertion) Equals(t testing.TB, want any) *FieldAssertion {
+	t.Helper()
+	if !a.exists(t) {
+		return a
+	}
+	wantJSON, err := normalizeJSONValue(want)
+	if err != nil {
+		t.Errorf("%s: cannot normalize expected value of field %s: %s", a.resource, a.path, err)
+		return a
+	}
+	gotJSON, err := normalizeJSONValue(a.value)
+	if err != nil {
+		t.Errorf("%s: cannot normalize value of field %s: %s", a.resource, a.path, err)
+		return a
+	}
+	if diff := cmp.Diff(wantJSON, gotJSON); diff != "" {
+		t.Errorf("%s: %s: -want +got\n%s\n", a.resource, a.path, diff)
+	}
+	return a
+}
+
+func (a *FieldAssertion) exists(t testing.TB) bool {
+	t.Helper()
+	switch {
+	case a.missingResource:
+		t.Errorf("%s: resource does not exist", a.resource)
+		return false
+	case fieldpath.IsNotFound(a.err):
+		t.Errorf("%s: expected field %s to exist", a.resource, a.path)
+		return false
+	case a.err != nil:
+		t.Errorf("%s: cannot get field %s: %s", a.resource, a.path, a.err)
+		return false
+	}
+	return true
+}
+
+// normalizeJSONValue converts v to its generic JSON representation.
+func normalizeJSONValue(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResultsAssertion provides assertions on the results of a function.
+type ResultsAssertion struct {
+	results []*fnapi.Result
+}
+
+// Len fails the test if the number of results is not n.
+func (a *ResultsAssertion) Len(t testing.TB, n int) *ResultsAssertion {
+	t.Helper()
+	if len(a.results) != n {
+		t.Errorf("results: expected %d results, got %d%s", n, len(a.results), formatResults(a.results))
+	}
+	return a
+}
+
+// HasFatal fails the test if there is no fatal result.
+func (a *ResultsAssertion) HasFatal(t testing.TB) *ResultsAssertion {
+	t.Helper()
+	return a.HasSeverity(t, fnapi.Severity_SEVERITY_FATAL)
+}
+
+// HasNoFatal fails the test if there is a fatal result.
+func (a *ResultsAssertion) HasNoFatal(t testing.TB) *ResultsAssertion {
+	t.Helper()
+	for _, r := range a.results {
+		if r.GetSeverity() == fnapi.Severity_SEVERITY_FATAL {
+			t.Errorf("results: expected no fatal result, got %q", r.GetMessage())
+		}
+	}
+	return a
+}
+
+// HasSeverity fails the test if there is no result with the given severity.
+func (a *ResultsAssertion) HasSeverity(t testing.TB, sev fnapi.Severity) *ResultsAssertion {
+	t.Helper()
+	for _, r := range a.results {
+		if r.GetSeverity() == sev {
+			return a
+		}
+	}
+	t.Errorf("results: expected a result with severity %s%s", sev.String(), formatResults(a.results))
+	return a
+}
+
+// HasMessage fails the test if there is no result whose message contains
+// substr.
+func (a *ResultsAssertion) HasMessage(t testing.TB, substr string) *ResultsAssertion {
+	t.Helper()
+	for _, r := range a.results {
+		if strings.Contains(r.GetMessage(), substr) {
+			return a
+		}
+	}
+	t.Errorf("results: expected a result with message containing %q%s", substr, formatResults(a.results))
+	return a
+}
+
+func formatResults(results []*fnapi.Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+	b := &strings.Builder{}
+	for i, r := range results {
+		fmt.Fprintf(b, "\nResult %d: %s: %s", i, r.GetSeverity().String(), r.GetMessage())
+	}
+	return b.String()
+}
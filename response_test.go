@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"strings"
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func testResponse(t *testing.T) *fnapi.RunFunctionResponse {
+	t.Helper()
+	resource := func(o map[string]any) *structpb.Struct {
+		s, err := structpb.NewStruct(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	return &fnapi.RunFunctionResponse{
+		Desired: &fnapi.State{
+			Composite: &fnapi.Resource{Resource: resource(map[string]any{
+				"apiVersion": "example.org/v1",
+				"kind":       "XBucket",
+				"status":     map[string]any{"region": "eu"},
+			})},
+			Resources: map[string]*fnapi.Resource{
+				"bucket": {
+					Resource: resource(map[string]any{
+						"apiVersion": "example.org/v1",
+						"kind":       "Bucket",
+						"metadata": map[string]any{
+							"labels":      map[string]any{"team": "a"},
+							"annotations": map[string]any{"note": "x"},
+						},
+						"spec": map[string]any{"forProvider": map[string]any{"region": "eu", "size": 1}},
+					}),
+					Ready: fnapi.Ready_READY_TRUE,
+				},
+				// malformed has no kind, so it cannot be converted.
+				"malformed": {Resource: resource(map[string]any{"spec": map[string]any{}})},
+			},
+		},
+		Results: []*fnapi.Result{
+			{Severity: fnapi.Severity_SEVERITY_NORMAL, Message: "created bucket"},
+			{Severity: fnapi.Severity_SEVERITY_WARNING, Message: "slow"},
+		},
+	}
+}
+
+type testBucket struct {
+	Spec struct {
+		ForProvider struct {
+			Region string `json:"region"`
+			Size   int    `json:"size"`
+		} `json:"forProvider"`
+	} `json:"spec"`
+}
+
+func TestResponse(t *testing.T) {
+	cases := map[string]struct {
+		assert     func(t testing.TB, r *Response)
+		wantErrors []string
+		wantFatal  bool
+	}{
+		"ResourceExists": {
+			assert: func(t testing.TB, r *Response) { r.Resource("bucket").Exists(t).IsReady(t, fnapi.Ready_READY_TRUE) },
+		},
+		"ResourceMissing": {
+			assert:     func(t testing.TB, r *Response) { r.Resource("missing").Exists(t).HasLabel(t, "team", "a") },
+			wantErrors: []string{"missing: expected resource to exist", "missing: resource does not exist"},
+		},
+		"ResourceNotExists": {
+			assert:     func(t testing.TB, r *Response) { r.Resource("missing").NotExists(t); r.Resource("bucket").NotExists(t) },
+			wantErrors: []string{"bucket: expected resource not to exist"},
+		},
+		"NotReady": {
+			assert:     func(t testing.TB, r *Response) { r.Resource("bucket").IsReady(t, fnapi.Ready_READY_FALSE) },
+			wantErrors: []string{"bucket: expected ready state READY_FALSE, got READY_TRUE"},
+		},
+		"Labels": {
+			assert: func(t testing.TB, r *Response) {
+				r.Resource("bucket").HasLabel(t, "team", "a").HasLabel(t, "team", "b").HasLabel(t, "owner", "a")
+			},
+			wantErrors: []string{`bucket: expected label "team" to be "b", got "a"`, `bucket: expected label "owner" to exist`},
+		},
+		"Annotations": {
+			assert: func(t testing.TB, r *Response) {
+				r.Resource("bucket").HasAnnotation(t, "note", "x").HasAnnotation(t, "note", "y")
+			},
+			wantErrors: []string{`bucket: expected annotation "note" to be "y", got "x"`},
+		},
+		"FieldEquals": {
+			assert: func(t testing.TB, r *Response) {
+				r.Resource("bucket").Spec("forProvider.region").Equals(t, "eu")
+				r.Resource("bucket").Spec("forProvider.size").Equals(t, 1)
+				r.Resource("bucket").Field("spec.forProvider").Equals(t, map[string]any{"region": "eu", "size": 1.0})
+				r.Composite().Status("region").Exists(t).Equals(t, "eu")
+			},
+		},
+		"FieldNotEqual": {
+			assert:     func(t testing.TB, r *Response) { r.Resource("bucket").Spec("forProvider.region").Equals(t, "us") },
+			wantErrors: []string{"bucket: spec.forProvider.region: -want +got"},
+		},
+		"FieldMissing": {
+			assert: func(t testing.TB, r *Response) {
+				r.Resource("bucket").Spec("forProvider.zone").Exists(t).NotExists(t)
+				r.Resource("bucket").Spec("forProvider.region").NotExists(t)
+				r.Resource("missing").Spec("region").Equals(t, "eu")
+			},
+			wantErrors: []string{
+				"bucket: expected field spec.forProvider.zone to exist",
+				"bucket: expected field spec.forProvider.region not to exist, got eu",
+				"missing: resource does not exist",
+			},
+		},
+		"Results": {
+			assert: func(t testing.TB, r *Response) {
+				r.Results().Len(t, 2).HasNoFatal(t).HasSeverity(t, fnapi.Severity_SEVERITY_WARNING).HasMessage(t, "created")
+			},
+		},
+		"ResultsFail": {
+			assert: func(t testing.TB, r *Response) {
+				r.Results().Len(t, 1).HasFatal(t).HasMessage(t, "deleted")
+			},
+			wantErrors: []string{
+				"results: expected 1 results, got 2\nResult 0: SEVERITY_NORMAL: created bucket",
+				"results: expected a result with severity SEVERITY_FATAL",
+				`results: expected a result with message containing "deleted"`,
+			},
+		},
+		"Decode": {
+			assert: func(t testing.TB, r *Response) {
+				b := Resource[testBucket](r, "bucket")
+				if b.Spec.ForProvider.Region != "eu" || b.Spec.ForProvider.Size != 1 {
+					t.Errorf("decoded %+v", b)
+				}
+			},
+		},
+		"DecodeMissing": {
+			assert:     func(_ testing.TB, r *Response) { Resource[testBucket](r, "missing") },
+			wantErrors: []string{"missing: resource does not exist"},
+			wantFatal:  true,
+		},
+		"MalformedResource": {
+			assert: func(t testing.TB, r *Response) {
+				a := r.Resource("malformed").Exists(t).HasLabel(t, "team", "a")
+				a.Spec("region").Equals(t, "eu")
+				if a.Object() != nil {
+					t.Errorf("Object() of a malformed resource is not nil")
+				}
+			},
+			wantErrors: []string{
+				"malformed: cannot convert resource:",
+				"malformed: cannot convert resource:",
+				"malformed: cannot get field spec.region: cannot convert resource:",
+			},
+		},
+		"DecodeMalformed": {
+			assert:     func(_ testing.TB, r *Response) { Resource[testBucket](r, "malformed") },
+			wantErrors: []string{"malformed: cannot convert resource:"},
+			wantFatal:  true,
+		},
+		"EmptyResponse": {
+			assert: func(t testing.TB, _ *Response) {
+				NewResponse(t, nil).Composite().NotExists(t)
+				NewResponse(t, nil).Results().Len(t, 0).HasNoFatal(t)
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ft := &fakeTB{TB: t}
+			r := NewResponse(ft, testResponse(t))
+			ft.run(func() { c.assert(ft, r) })
+
+			if len(ft.errors) != len(c.wantErrors) {
+				t.Fatalf("want %d failures, got %q", len(c.wantErrors), ft.errors)
+			}
+			for i, want := range c.wantErrors {
+				if !strings.HasPrefix(ft.errors[i], want) {
+					t.Errorf("failure %d: want prefix %q, got %q", i, want, ft.errors[i])
+				}
+			}
+			if ft.fatal != c.wantFatal {
+				t.Errorf("want fatal %t, got %t", c.wantFatal, ft.fatal)
+			}
+		})
+	}
+}
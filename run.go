@@ -5,18 +5,40 @@ package testing
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/schema"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/goroutine"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
 )
 
 const (
 	testRequestMetaTag = "go-test"
+
+	// cancellationGracePeriod is the time a function is given to return after
+	// its context was cancelled.
+	cancellationGracePeriod = time.Second
 )
 
+// TestFunctionOpt configures a [FunctionTest].
+//
+// Options may be reused across tests, including parallel subtests: they must
+// not modify the fixtures they were created from and must only assign data to
+// the given test that is not shared with other tests.
 type TestFunctionOpt func(tc *FunctionTest)
 
 func generateTc(fn fnapi.FunctionRunnerServiceServer) *FunctionTest {
@@ -60,8 +82,14 @@ func TestFunctionGetResult(t *testing.T, fn fnapi.FunctionRunnerServiceServer, o
 	for _, o := range opts {
 		o(tc)
 	}
+	if err := tc.setup(); err != nil {
+		t.Fatal(errors.Wrapf(err, "cannot set up test"))
+	}
 
 	res, err := tc.generateResponse()
+	if tc.evaluatePanic(t, err) {
+		return res
+	}
 	if err != nil {
 		t.Fatal(errors.Wrapf(err, "cannot generate response"))
 	}
@@ -70,15 +98,106 @@ func TestFunctionGetResult(t *testing.T, fn fnapi.FunctionRunnerServiceServer, o
 }
 
 func TestFunction(t *testing.T, fn fnapi.FunctionRunnerServiceServer, opts ...TestFunctionOpt) {
+	runFunctionTest(t, fn, opts...)
+}
+
+// runFunctionTest implements [TestFunction] for any [testing.TB].
+func runFunctionTest(t testing.TB, fn fnapi.FunctionRunnerServiceServer, opts ...TestFunctionOpt) {
+	start := time.Now()
+	var assertions []Assertion
+	// Deferred, so assertions are also recorded if the test fails early.
+	defer func() {
+		report.record(testReport{Test: t.Name(), Elapsed: time.Since(start).Seconds(), Assertions: assertions})
+	}()
 	tc := generateTc(fn)
 
 	// Apply user options
 	for _, o := range opts {
 		o(tc)
 	}
+	if err := tc.setup(); err != nil {
+		err = errors.Wrapf(err, "cannot set up test")
+		assertions = []Assertion{failedAssertion("Setup", err.Error(), "")}
+		t.Fatal(err)
+	}
+
+	res, err := tc.generateResponse()
+	if a, ok := tc.panicAssertion(err); ok {
+		assertions = []Assertion{a}
+		tc.evaluatePanic(t, err)
+		return
+	}
+	tc.writeArtifactsOnFailure(t, res)
+	assertions = tc.evaluate(res, err)
+	reportAssertions(t, assertions)
+}
+
+// EvaluateFunction runs the function like [TestFunction] but returns the
+// result of every assertion instead of reporting them to a test. If
+// reporting is enabled, the assertions are recorded under the given name.
+//
+// Options that panic and failed setups are returned as failed assertion named
+// "Setup" and panics of the function as assertion named "Panic". Options that
+// depend on a test, like [WithArtifactsOnFailure], have no effect.
+func EvaluateFunction(name string, fn fnapi.FunctionRunnerServiceServer, opts ...TestFunctionOpt) (assertions []Assertion) {
+	start := time.Now()
+	defer func() {
+		report.record(testReport{Test: name, Elapsed: time.Since(start).Seconds(), Assertions: assertions})
+	}()
+
+	tc, err := func() (tc *FunctionTest, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = errors.Errorf("invalid option: %v", v)
+			}
+		}()
+		tc = generateTc(fn)
+		for _, o := range opts {
+			o(tc)
+		}
+		return tc, tc.setup()
+	}()
+	if err != nil {
+		return []Assertion{failedAssertion("Setup", errors.Wrap(err, "cannot set up test").Error(), "")}
+	}
 
 	res, err := tc.generateResponse()
-	tc.compareResponseToExpectedResources(t, res, err)
+	if a, ok := tc.panicAssertion(err); ok {
+		return []Assertion{a}
+	}
+	return tc.evaluate(res, err)
+}
+
+// panicAssertion returns the assertion named "Panic" for a function run that
+// returned err. It returns false if no panic was expected and none occurred.
+func (tc *FunctionTest) panicAssertion(err error) (Assertion, bool) {
+	var p *functionPanic
+	panicked := errors.As(err, &p)
+	switch {
+	case panicked && tc.expectPanic == nil:
+		return failedAssertion("Panic", fmt.Sprintf("function panicked: %v", p.value), string(p.stack)), true
+	case panicked && !tc.expectPanic(p.value):
+		return failedAssertion("Panic", fmt.Sprintf("function panicked with unexpected value: %v", p.value), string(p.stack)), true
+	case panicked:
+		return passedAssertion("Panic"), true
+	case tc.expectPanic != nil:
+		return failedAssertion("Panic", fmt.Sprintf("expected function to panic, but it returned (error: %v)", err), ""), true
+	}
+	return Assertion{}, false
+}
+
+// evaluate evaluates all expectations of the test against the response and
+// error of the function.
+func (tc *FunctionTest) evaluate(res *fnapi.RunFunctionResponse, err error) []Assertion {
+	assertions := tc.compareResponseToExpectedResources(res, err)
+	assertions = append(assertions, tc.evaluateChecks(res, err)...)
+	// Recorded after the checks, which record the resources they asserted.
+	tc.recordCoverage(res, err)
+	assertions = append(assertions, tc.evaluatePolicies(res, err)...)
+	if err == nil && !slices.ContainsFunc(assertions, func(a Assertion) bool { return a.Status == AssertionFailed }) {
+		assertions = append(assertions, tc.evaluateMutants(res)...)
+	}
+	return assertions
 }
 
 type FunctionTest struct {
@@ -86,37 +205,356 @@ type FunctionTest struct {
 
 	req    *fnapi.RunFunctionRequest
 	reqCtx context.Context
-	res    *fnapi.RunFunctionResponse
-	err    error
+	// sentReq is the copy of req that was passed to the function.
+	sentReq *fnapi.RunFunctionRequest
+	res     *fnapi.RunFunctionResponse
+	err     error
+	// errChecks replace the comparison with err if set.
+	errChecks []func(err error) error
+	// yamlDiffs reports differences of the desired state as YAML diffs.
+	yamlDiffs bool
+	// allowExtraResources reports unexpected desired resources as warning.
+	allowExtraResources bool
+	// artifactsDir is the directory the response is written to on failure.
+	artifactsDir string
+	// mutationTesting evaluates the expectations against mutated responses.
+	mutationTesting bool
+
+	// timeout of the context passed to the function.
+	timeout time.Duration
+	// completeWithin is the duration the function is expected to return in.
+	completeWithin time.Duration
+	// completionErr describes why the function did not complete in time.
+	completionErr error
+	// leakGracePeriod enables the goroutine leak detection if greater than 0.
+	leakGracePeriod time.Duration
+	// leakedGoroutines are the stack traces of goroutines that were started
+	// by the function and did not exit.
+	leakedGoroutines []string
+	// expectPanic matches the value the function is expected to panic with.
+	// The function is not expected to panic if it is nil.
+	expectPanic func(v any) bool
+
+	setups []func() error
+	checks []responseCheck
+	// behaviorChecks are checks that compare the response with further runs
+	// of the function.
+	behaviorChecks []responseCheck
+	runChecks      []func() error
+	// checkedResources are the names of the desired composed resources that
+	// checks asserted, e.g. with ExpectCEL.
+	checkedResources map[string]bool
+	schemas          *schema.Validator
+
+	skipPolicies    map[string]bool
+	skipAllPolicies bool
+}
+
+// responseCheck is an additional expectation on the response of a function.
+// It returns an error that describes the violation, if any.
+type responseCheck func(res *fnapi.RunFunctionResponse) error
+
+// setup runs all setup steps that depend on the options being applied
+// completely, e.g. validations of the request.
+func (tc *FunctionTest) setup() error {
+	for _, s := range tc.setups {
+		if err := s(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (tc *FunctionTest) generateResponse() (*fnapi.RunFunctionResponse, error) {
-	res, err := tc.fn.RunFunction(tc.reqCtx, tc.req)
+	// Pass a copy to the function, so the request of the test stays intact
+	// even if the function mutates its input.
+	tc.sentReq = proto.Clone(tc.req).(*fnapi.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+
+	var before goroutine.Snapshot
+	if tc.leakGracePeriod > 0 {
+		before = goroutine.Take()
+	}
+
+	var res *fnapi.RunFunctionResponse
+	var err error
+	if tc.completeWithin > 0 {
+		res, err = tc.runFunctionWithin(tc.sentReq, tc.completeWithin)
+	} else {
+		res, err = tc.runFunction(tc.sentReq)
+	}
+
+	if tc.leakGracePeriod > 0 {
+		tc.leakedGoroutines = goroutine.WaitSince(before, tc.leakGracePeriod, goroutine.IgnoreTests())
+	}
+
+	return normalizeResponse(res), err
+}
 
+// normalizeResponse returns res with an empty response and desired state in
+// place of nil ones, so responses can be compared regardless of whether the
+// function set them.
+func normalizeResponse(res *fnapi.RunFunctionResponse) *fnapi.RunFunctionResponse {
 	if res == nil {
 		res = &fnapi.RunFunctionResponse{}
 	}
 	if res.GetDesired() == nil {
 		res.Desired = &fnapi.State{}
 	}
+	return res
+}
+
+// runFunction runs the function with req and the context of the test. A panic
+// of the function is recovered and returned as *functionPanic.
+func (tc *FunctionTest) runFunction(req *fnapi.RunFunctionRequest) (*fnapi.RunFunctionResponse, error) {
+	ctx, cancel := tc.requestContext()
+	defer cancel()
+	return tc.callFunction(ctx, req)
+}
 
-	return res, err
+// functionPanic is the error returned by callFunction if the function
+// panicked.
+type functionPanic struct {
+	value any
+	stack []byte
 }
 
-func (tc *FunctionTest) compareResponseToExpectedResources(t *testing.T, res *fnapi.RunFunctionResponse, err error) {
+func (p *functionPanic) Error() string {
+	return fmt.Sprintf("function panicked: %v\n\n%s", p.value, p.stack)
+}
+
+// callFunction calls the function and recovers from a panic of it.
+func (tc *FunctionTest) callFunction(ctx context.Context, req *fnapi.RunFunctionRequest) (res *fnapi.RunFunctionResponse, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			res, err = nil, &functionPanic{value: v, stack: debug.Stack()}
+		}
+	}()
+	return tc.fn.RunFunction(ctx, req)
+}
+
+// runFunctionWithin is the same as runFunction but cancels the context of the
+// function if it does not return within d. If the function does not return
+// within a grace period after the cancellation either, it gives up and
+// returns an error.
+func (tc *FunctionTest) runFunctionWithin(req *fnapi.RunFunctionRequest, d time.Duration) (*fnapi.RunFunctionResponse, error) {
+	type result struct {
+		res *fnapi.RunFunctionResponse
+		err error
+	}
+
+	before := goroutine.Take()
+	ctx, cancel := tc.requestContext()
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		res, err := tc.callFunction(ctx, req)
+		done <- result{res: res, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-time.After(d):
+	}
+
+	cancel()
+	select {
+	case r := <-done:
+		tc.completionErr = errors.Errorf("function did not complete within %s, it returned only after its context was cancelled (after %s)", d, time.Since(start).Round(time.Millisecond))
+		return r.res, r.err
+	case <-time.After(cancellationGracePeriod):
+	}
+
+	tc.completionErr = errors.Errorf("function did not complete within %s and did not return within %s after its context was cancelled; goroutines still running:\n\n%s",
+		d, cancellationGracePeriod, strings.Join(goroutine.Since(before), "\n\n"))
+	return nil, errors.New("function did not return after its context was cancelled")
+}
+
+// requestContext returns the context that is passed to the function.
+func (tc *FunctionTest) requestContext() (context.Context, context.CancelFunc) {
+	if tc.timeout > 0 {
+		return context.WithTimeout(tc.reqCtx, tc.timeout)
+	}
+	return context.WithCancel(tc.reqCtx)
+}
+
+// compareDesired compares the desired state of res to the expected one.
+func (tc *FunctionTest) compareDesired(res *fnapi.RunFunctionResponse) []Assertion {
+	assertions := []Assertion{passedAssertion("res.Desired.Composite")}
 	if diff := cmp.Diff(convertResourceToUnstructured(tc.res.GetDesired().GetComposite()), convertResourceToUnstructured(res.GetDesired().GetComposite())); diff != "" {
-		t.Errorf("res.Desired.Composite: -want +got\n%s\n", diff)
+		assertions[0] = failedAssertion("res.Desired.Composite", "-want +got", diff)
+	}
+	want := convertResourcesMapToUnstructured(tc.res.GetDesired().GetResources())
+	got := convertResourcesMapToUnstructured(res.GetDesired().GetResources())
+	names, a := tc.compareDesiredResourceNames(want, got)
+	assertions = append(assertions, a)
+	for _, name := range names {
+		a := passedAssertion(fmt.Sprintf("res.Desired.Resources[%q]", name))
+		if diff := cmp.Diff(want[name], got[name]); diff != "" {
+			a = failedAssertion(a.Name, "-want +got", diff)
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions
+}
+
+// compareDesiredResourceNames asserts that no expected resource is missing in
+// got and that got contains no unexpected resource, so this is reported
+// before any of their fields are compared. It returns the sorted names of all
+// resources that exist in both.
+func (tc *FunctionTest) compareDesiredResourceNames(want, got map[string]*unstructured.Unstructured) ([]string, Assertion) {
+	a := passedAssertion("res.Desired.Resources")
+	missing, unexpected := diffResourceNames(want, got)
+	switch {
+	case len(missing) > 0 || (len(unexpected) > 0 && !tc.allowExtraResources):
+		a = failedAssertion(a.Name, formatResourceNameDiff(missing, unexpected), "")
+	case len(unexpected) > 0:
+		a.Status = AssertionWarning
+		a.Message = formatResourceNameDiff(nil, unexpected)
 	}
-	if diff := cmp.Diff(convertResourcesMapToUnstructured(tc.res.GetDesired().GetResources()), convertResourcesMapToUnstructured(res.GetDesired().GetResources())); diff != "" {
-		t.Errorf("res.Desired.Resources: -want +got\n%s\n", diff)
+
+	names := []string{}
+	for _, name := range maps.SortedKeys(want) {
+		if _, exists := got[name]; exists {
+			names = append(names, name)
+		}
 	}
+	return names, a
+}
+
+// compareResponseToExpectedResources compares the response and error of the
+// function to the expected ones and returns the result of every comparison.
+func (tc *FunctionTest) compareResponseToExpectedResources(res *fnapi.RunFunctionResponse, err error) []Assertion {
+	var assertions []Assertion
+	if tc.yamlDiffs {
+		assertions = tc.compareDesiredYAML(res)
+	} else {
+		assertions = tc.compareDesired(res)
+	}
+
+	results := passedAssertion("Results")
 	if diff := cmp.Diff(convertResultsToMap(tc.res.GetResults()), convertResultsToMap(res.GetResults())); diff != "" {
-		t.Errorf("Results: -want +got\n%s\n", diff)
+		got := make([]string, len(res.GetResults()))
 		for i, r := range res.GetResults() {
-			t.Errorf("Result %d: %s: %s", i, r.GetSeverity().String(), r.GetMessage())
+			got[i] = fmt.Sprintf("Result %d: %s: %s", i, r.GetSeverity().String(), r.GetMessage())
 		}
+		results = failedAssertion(results.Name, "-want +got", diff+strings.Join(got, "\n"))
+	}
+	assertions = append(assertions, results)
+
+	errAssertion := passedAssertion("Error")
+	if len(tc.errChecks) > 0 {
+		var failed []string
+		for _, c := range tc.errChecks {
+			if err := c(err); err != nil {
+				failed = append(failed, err.Error())
+			}
+		}
+		if len(failed) > 0 {
+			errAssertion = failedAssertion(errAssertion.Name, strings.Join(failed, "; "), "")
+		}
+	} else if diff := cmp.Diff(tc.err, err); diff != "" {
+		errAssertion = failedAssertion(errAssertion.Name, "-want +got", diff)
+	}
+	assertions = append(assertions, errAssertion)
+
+	if len(tc.res.GetContext().GetFields()) > 0 {
+		assertions = append(assertions, tc.compareContext(res))
+	}
+	return assertions
+}
+
+// compareContext compares all expected fields of the context to the ones of
+// the response. Other fields of the context are ignored.
+func (tc *FunctionTest) compareContext(res *fnapi.RunFunctionResponse) Assertion {
+	want := map[string]any{}
+	got := map[string]any{}
+	for k, v := range tc.res.GetContext().GetFields() {
+		want[k] = v.AsInterface()
+		if v, exists := res.GetContext().GetFields()[k]; exists {
+			got[k] = v.AsInterface()
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		return failedAssertion("Context", "-want +got", diff)
+	}
+	return passedAssertion("Context")
+}
+
+// evaluateChecks evaluates all additional expectations against the function
+// run and its response. Expectations on the response are skipped if the
+// function returned an error, because the response is expected to be empty in
+// that case.
+func (tc *FunctionTest) evaluateChecks(res *fnapi.RunFunctionResponse, err error) []Assertion {
+	if len(tc.runChecks) == 0 && len(tc.checks) == 0 && len(tc.behaviorChecks) == 0 {
+		return nil
+	}
+	var failed []string
+	for _, c := range tc.runChecks {
+		if err := c(); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if err == nil {
+		for _, c := range slices.Concat(tc.checks, tc.behaviorChecks) {
+			if err := c(res); err != nil {
+				failed = append(failed, err.Error())
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return []Assertion{failedAssertion("Expectations", strings.Join(failed, "\n"), "")}
+	}
+	return []Assertion{passedAssertion("Expectations")}
+}
+
+// evaluatePanic reports a panic of the function if it was not expected and a
+// missing or mismatching panic if it was. It returns true if a panic was
+// expected or occurred, in which case no other expectation is evaluated.
+//
+// Unexpected panics fail the test immediately. The request is written to a
+// temporary file to reproduce the panic.
+func (tc *FunctionTest) evaluatePanic(t testing.TB, err error) bool {
+	t.Helper()
+	var p *functionPanic
+	panicked := errors.As(err, &p)
+	switch {
+	case panicked && tc.expectPanic == nil:
+		msg := fmt.Sprintf("Function panicked: %v\n\n%s", p.value, p.stack)
+		if path, err := writeRequestYAML(t, tc.req); err != nil {
+			msg += fmt.Sprintf("\nCannot write request: %s", err)
+		} else {
+			msg += fmt.Sprintf("\nRequest written to %s", path)
+		}
+		t.Fatal(msg)
+	case panicked && !tc.expectPanic(p.value):
+		t.Errorf("Function panicked with unexpected value: %v\n\n%s", p.value, p.stack)
+	case !panicked && tc.expectPanic != nil:
+		t.Errorf("Expected function to panic, but it returned (error: %v)", err)
+	}
+	return panicked || tc.expectPanic != nil
+}
+
+// writeRequestYAML writes req as YAML to a new temporary file and returns its
+// path. The file is kept after the test to reproduce failures.
+func writeRequestYAML(t testing.TB, req *fnapi.RunFunctionRequest) (string, error) {
+	v, err := protoAsJSONValue(req)
+	if err != nil {
+		return "", err
+	}
+	raw, err := yaml.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot marshal request")
+	}
+	f, err := os.CreateTemp("", strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())+"-request-*.yaml")
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create file")
 	}
-	if diff := cmp.Diff(tc.err, err); diff != "" {
-		t.Errorf("Error: -want +got\n%s\n", diff)
+	defer f.Close() //nolint:errcheck // Errors of writes are handled.
+	if _, err := f.Write(raw); err != nil {
+		return "", errors.Wrap(err, "cannot write file")
 	}
+	return f.Name(), nil
 }
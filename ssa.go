@@ -0,0 +1,243 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/maps"
+)
+
+// crossplaneFieldManagerPrefix is the prefix of all field managers that
+// Crossplane uses to apply composite and composed resources.
+const crossplaneFieldManagerPrefix = "apiextensions.crossplane.io/"
+
+// FieldOwnershipReport describes the effects of applying a desired state with
+// server-side apply after a previous desired state has been applied by the
+// same field manager.
+//
+// Field sets are deduced from the objects, i.e. maps are granular and lists
+// are atomic, as no schema is known.
+type FieldOwnershipReport struct {
+	// RemovedResources are the names of the composed resources that are part
+	// of the previous desired state but not of the current one. Crossplane
+	// deletes them.
+	RemovedResources []string
+
+	// DroppedFields are the paths of all fields per composed resource that
+	// are part of the previous desired state but not of the current one.
+	// Server-side apply removes them from the resource.
+	DroppedFields map[string][]string
+
+	// Conflicts are the paths of all fields per composed resource that are
+	// part of the current desired state, are owned by another field manager
+	// in the observed state and have a different value than observed.
+	Conflicts map[string][]string
+
+	// CompositeDroppedFields are the dropped fields of the composite.
+	CompositeDroppedFields []string
+
+	// CompositeConflicts are the conflicting fields of the composite.
+	CompositeConflicts []string
+}
+
+// Empty returns whether the report contains no removed resources, dropped
+// fields or conflicts.
+func (r *FieldOwnershipReport) Empty() bool {
+	return len(r.RemovedResources) == 0 && len(r.DroppedFields) == 0 && len(r.Conflicts) == 0 &&
+		len(r.CompositeDroppedFields) == 0 && len(r.CompositeConflicts) == 0
+}
+
+// String returns a human readable description of the report.
+func (r *FieldOwnershipReport) String() string {
+	b := &strings.Builder{}
+	for _, p := range r.CompositeDroppedFields {
+		fmt.Fprintf(b, "composite resource: field %s dropped\n", p)
+	}
+	for _, p := range r.CompositeConflicts {
+		fmt.Fprintf(b, "composite resource: field %s conflicts with another field manager\n", p)
+	}
+	for _, name := range r.RemovedResources {
+		fmt.Fprintf(b, "%s: resource removed\n", name)
+	}
+	for _, name := range maps.SortedKeys(r.DroppedFields) {
+		for _, p := range r.DroppedFields[name] {
+			fmt.Fprintf(b, "%s: field %s dropped\n", name, p)
+		}
+	}
+	for _, name := range maps.SortedKeys(r.Conflicts) {
+		for _, p := range r.Conflicts[name] {
+			fmt.Fprintf(b, "%s: field %s conflicts with another field manager\n", name, p)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// CompareFieldOwnership simulates applying the current desired state after the
+// previous one with server-side apply field ownership semantics and reports
+// fields that would be dropped or conflict. The observed state is used to
+// determine fields owned by other field managers and may be nil.
+func CompareFieldOwnership(previous, current, observed *fnapi.State) (*FieldOwnershipReport, error) {
+	report := &FieldOwnershipReport{
+		DroppedFields: map[string][]string{},
+		Conflicts:     map[string][]string{},
+	}
+
+	var err error
+	report.CompositeDroppedFields, report.CompositeConflicts, err = compareObjectFieldOwnership(
+		convertResourceToUnstructured(previous.GetComposite()),
+		convertResourceToUnstructured(current.GetComposite()),
+		convertResourceToUnstructured(observed.GetComposite()),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "composite")
+	}
+
+	prev := stateResourceObjects(previous)
+	cur := stateResourceObjects(current)
+	obs := stateResourceObjects(observed)
+
+	for _, name := range maps.SortedKeys(prev) {
+		if _, exists := cur[name]; !exists {
+			report.RemovedResources = append(report.RemovedResources, name)
+		}
+	}
+
+	for _, name := range maps.SortedKeys(cur) {
+		dropped, conflicts, err := compareObjectFieldOwnership(prev[name], cur[name], obs[name])
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		if len(dropped) > 0 {
+			report.DroppedFields[name] = dropped
+		}
+		if len(conflicts) > 0 {
+			report.Conflicts[name] = conflicts
+		}
+	}
+	return report, nil
+}
+
+// compareObjectFieldOwnership returns the fields of the previous desired
+// object that the current one drops and the fields of the current one that
+// conflict with other field managers of the observed object. It returns no
+// fields if current is nil, and no dropped fields or conflicts if previous or
+// observed is nil, respectively.
+func compareObjectFieldOwnership(previous, current, observed *unstructured.Unstructured) (dropped, conflicts []string, err error) {
+	if current == nil {
+		return nil, nil, nil
+	}
+	curSet, err := deducedFieldSet(current)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot compute fields of current desired state")
+	}
+
+	if previous != nil {
+		prevSet, err := deducedFieldSet(previous)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot compute fields of previous desired state")
+		}
+		dropped = fieldSetPaths(prevSet.Difference(curSet).Leaves())
+	}
+
+	if observed != nil {
+		conflicts, err = conflictingFields(observed, current, curSet)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot compute conflicts")
+		}
+	}
+	return dropped, conflicts, nil
+}
+
+// ExpectStableFieldOwnership expects that applying the desired state of the
+// function after the previous desired state neither removes resources, drops
+// fields nor conflicts with other field managers of the observed state. See
+// [CompareFieldOwnership].
+//
+// This detects fields that "flap" between reconciliations, e.g. because they
+// are only set conditionally.
+func ExpectStableFieldOwnership(previous *fnapi.State) TestFunctionOpt {
+	return func(tc *FunctionTest) {
+		tc.checks = append(tc.checks, func(res *fnapi.RunFunctionResponse) error {
+			report, err := CompareFieldOwnership(previous, res.GetDesired(), tc.req.GetObserved())
+			if err != nil {
+				return errors.Wrap(err, "cannot compare field ownership")
+			}
+			if !report.Empty() {
+				return errors.Errorf("Field ownership is not stable:\n%s", report.String())
+			}
+			return nil
+		})
+	}
+}
+
+// stateResourceObjects returns all composed resources of s by name.
+func stateResourceObjects(s *fnapi.State) map[string]*unstructured.Unstructured {
+	out := map[string]*unstructured.Unstructured{}
+	for name, r := range s.GetResources() {
+		out[name] = convertResourceToUnstructured(r)
+	}
+	return out
+}
+
+func deducedFieldSet(u *unstructured.Unstructured) (*fieldpath.Set, error) {
+	tv, err := typed.DeducedParseableType.FromUnstructured(u.UnstructuredContent())
+	if err != nil {
+		return nil, err
+	}
+	return tv.ToFieldSet()
+}
+
+// conflictingFields returns the paths of all fields of desired that are owned
+// by a field manager other than Crossplane in observed and whose value
+// differs.
+func conflictingFields(observed, desired *unstructured.Unstructured, desiredSet *fieldpath.Set) ([]string, error) {
+	foreign := &fieldpath.Set{}
+	for _, mf := range observed.GetManagedFields() {
+		if strings.HasPrefix(mf.Manager, crossplaneFieldManagerPrefix) || mf.FieldsV1 == nil {
+			continue
+		}
+		s := &fieldpath.Set{}
+		if err := s.FromJSON(bytes.NewReader(mf.FieldsV1.Raw)); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse managed fields of %s", mf.Manager)
+		}
+		foreign = foreign.Union(s)
+	}
+	if foreign.Empty() {
+		return nil, nil
+	}
+
+	obsTV, err := typed.DeducedParseableType.FromUnstructured(observed.UnstructuredContent())
+	if err != nil {
+		return nil, err
+	}
+	desTV, err := typed.DeducedParseableType.FromUnstructured(desired.UnstructuredContent())
+	if err != nil {
+		return nil, err
+	}
+	cmp, err := obsTV.Compare(desTV)
+	if err != nil {
+		return nil, err
+	}
+	changed := cmp.Modified.Union(cmp.Added)
+	return fieldSetPaths(changed.Intersection(foreign).Intersection(desiredSet).Leaves()), nil
+}
+
+func fieldSetPaths(s *fieldpath.Set) []string {
+	paths := []string{}
+	s.Iterate(func(p fieldpath.Path) {
+		paths = append(paths, strings.TrimPrefix(p.String(), "."))
+	})
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"testing"
+
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+func mustStateYAML(t *testing.T, compositeYAML string, resourcesYAML map[string]string) *fnapi.State {
+	t.Helper()
+	toResource := func(rawYAML string) *fnapi.Resource {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(rawYAML), &u.Object); err != nil {
+			t.Fatal(err)
+		}
+		return &fnapi.Resource{Resource: mustObjectAsStruct(u)}
+	}
+	s := &fnapi.State{Resources: map[string]*fnapi.Resource{}}
+	if compositeYAML != "" {
+		s.Composite = toResource(compositeYAML)
+	}
+	for name, rawYAML := range resourcesYAML {
+		s.Resources[name] = toResource(rawYAML)
+	}
+	return s
+}
+
+func TestCompareFieldOwnership(t *testing.T) {
+	const composite = `{apiVersion: example.org/v1, kind: XBucket, status: {ready: true, region: eu}}`
+	const bucket = `{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu, tags: {team: a}}}`
+
+	cases := map[string]struct {
+		previous *fnapi.State
+		current  *fnapi.State
+		observed *fnapi.State
+		want     *FieldOwnershipReport
+	}{
+		"Stable": {
+			previous: mustStateYAML(t, composite, map[string]string{"bucket": bucket}),
+			current:  mustStateYAML(t, composite, map[string]string{"bucket": bucket}),
+			want:     &FieldOwnershipReport{},
+		},
+		"RemovedResource": {
+			previous: mustStateYAML(t, "", map[string]string{"bucket": bucket, "policy": bucket}),
+			current:  mustStateYAML(t, "", map[string]string{"bucket": bucket}),
+			want:     &FieldOwnershipReport{RemovedResources: []string{"policy"}},
+		},
+		"DroppedFields": {
+			previous: mustStateYAML(t, composite, map[string]string{"bucket": bucket}),
+			current: mustStateYAML(t, `{apiVersion: example.org/v1, kind: XBucket, status: {ready: true}}`, map[string]string{
+				"bucket": `{apiVersion: example.org/v1, kind: Bucket, spec: {region: eu}}`,
+			}),
+			want: &FieldOwnershipReport{
+				DroppedFields:          map[string][]string{"bucket": {"spec.tags.team"}},
+				CompositeDroppedFields: []string{"status.region"},
+			},
+		},
+		"ResourceNamedComposite": {
+			previous: mustStateYAML(t, composite, map[string]string{"composite": bucket}),
+			current:  mustStateYAML(t, composite, map[string]string{"composite": bucket}),
+			want:     &FieldOwnershipReport{},
+		},
+		"RemovedResourceNamedComposite": {
+			previous: mustStateYAML(t, composite, map[string]string{"composite": bucket}),
+			current:  mustStateYAML(t, composite, nil),
+			want:     &FieldOwnershipReport{RemovedResources: []string{"composite"}},
+		},
+		"Conflicts": {
+			previous: mustStateYAML(t, "", map[string]string{"bucket": bucket}),
+			current:  mustStateYAML(t, "", map[string]string{"bucket": `{apiVersion: example.org/v1, kind: Bucket, spec: {region: us, tags: {team: a}}}`}),
+			observed: mustStateYAML(t, "", map[string]string{"bucket": `
+apiVersion: example.org/v1
+kind: Bucket
+metadata:
+  managedFields:
+    - manager: apiextensions.crossplane.io/composed
+      operation: Apply
+      fieldsType: FieldsV1
+      fieldsV1: {f:spec: {f:tags: {f:team: {}}}}
+    - manager: kubectl
+      operation: Apply
+      fieldsType: FieldsV1
+      fieldsV1: {f:spec: {f:region: {}}}
+spec:
+  region: eu
+  tags:
+    team: a
+`}),
+			want: &FieldOwnershipReport{Conflicts: map[string][]string{"bucket": {"spec.region"}}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := CompareFieldOwnership(c.previous, c.current, c.observed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+			if got.Empty() != (c.want.String() == "") {
+				t.Errorf("Empty() is %t for report:\n%s", got.Empty(), got.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	fnapi "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/dsd-dbs/crossplane-function-test-framework/internal/util/yaml"
+)
+
+// SyntheticModifier modifies the i-th object generated by
+// [SyntheticResources].
+type SyntheticModifier func(i int, u *unstructured.Unstructured)
+
+// VaryField sets the field at path, e.g. "spec.forProvider.size", of the i-th
+// object to value(i).
+func VaryField(path string, value func(i int) any) SyntheticModifier {
+	return func(i int, u *unstructured.Unstructured) {
+		if err := fieldpath.Pave(u.Object).SetValue(path, value(i)); err != nil {
+			panic(errors.Wrapf(err, "cannot set field %q", path).Error())
+		}
+	}
+}
+
+// VaryFieldf sets the field at path of the i-th object to the string
+// fmt.Sprintf(format, i).
+func VaryFieldf(path, format string) SyntheticModifier {
+	return VaryField(path, func(i int) any {
+		return fmt.Sprintf(format, i)
+	})
+}
+
+// SyntheticResources generates n objects from the single YAML document
+// templateYAML, e.g. to test how a function behaves with large compositions.
+//
+// The [AnnotationKeyResourceName] and "crossplane.io/composition-resource-name"
+// annotations and the metadata.name of the template are suffixed with the
+// index of the object, e.g. "bucket-0", "bucket-1", ..., so every object is
+// unique. The modifiers are applied to every object afterwards.
+func SyntheticResources(templateYAML []byte, n int, mods ...SyntheticModifier) []*unstructured.Unstructured {
+	template := mustUnstructuredFromYAML(templateYAML)
+	if GetTestResourceName(template) == "" {
+		panic(fmt.Sprintf("resource has no name annotation: %s/%s", template.GroupVersionKind().String(), template.GetName()))
+	}
+
+	objects := make([]*unstructured.Unstructured, n)
+	for i := range n {
+		u := template.DeepCopy()
+		ann := u.GetAnnotations()
+		for _, k := range []string{AnnotationKeyResourceName, annotationKeyCompositionResourceName} {
+			if name, exists := ann[k]; exists {
+				ann[k] = fmt.Sprintf("%s-%d", name, i)
+			}
+		}
+		u.SetAnnotations(ann)
+		if name := u.GetName(); name != "" {
+			u.SetName(fmt.Sprintf("%s-%d", name, i))
+		}
+		for _, m := range mods {
+			m(i, u)
+		}
+		objects[i] = u
+	}
+	return objects
+}
+
+// SyntheticResourcesYAML is the same as [SyntheticResources] but returns the
+// objects as multi-document YAML, which can be passed to
+// [WithObservedResourcesYAML] or [ExpectDesiredResourcesYAML].
+func SyntheticResourcesYAML(templateYAML []byte, n int, mods ...SyntheticModifier) []byte {
+	raw, err := yaml.MarshalObjects(SyntheticResources(templateYAML, n, mods...))
+	if err != nil {
+		panic(err.Error())
+	}
+	return raw
+}
+
+// WithSyntheticObservedResources adds the n objects generated by
+// [SyntheticResources] to the observed state passed to the function.
+func WithSyntheticObservedResources(templateYAML []byte, n int, mods ...SyntheticModifier) TestFunctionOpt {
+	objects := SyntheticResources(templateYAML, n, mods...)
+	return func(tc *FunctionTest) {
+		for _, o := range objects {
+			u := o.DeepCopy()
+			key := GetTestResourceName(u)
+			meta.RemoveAnnotations(u, AnnotationKeyResourceName)
+			tc.req.Observed.Resources[key] = &fnapi.Resource{
+				Resource: mustObjectAsStruct(u),
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package testing
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSyntheticResources(t *testing.T) {
+	cases := map[string]struct {
+		template string
+		want     []map[string]string
+	}{
+		"TestAnnotation": {
+			template: `{apiVersion: example.org/v1, kind: Bucket, metadata: {name: b, annotations: {fn.test/resource-name: bucket}}}`,
+			want: []map[string]string{
+				{"name": "b-0", AnnotationKeyResourceName: "bucket-0"},
+				{"name": "b-1", AnnotationKeyResourceName: "bucket-1"},
+			},
+		},
+		"CompositionResourceName": {
+			template: `{apiVersion: example.org/v1, kind: Bucket, metadata: {annotations: {crossplane.io/composition-resource-name: bucket}}}`,
+			want: []map[string]string{
+				{annotationKeyCompositionResourceName: "bucket-0"},
+				{annotationKeyCompositionResourceName: "bucket-1"},
+			},
+		},
+		"BothAnnotations": {
+			template: `{apiVersion: example.org/v1, kind: Bucket, metadata: {annotations: {fn.test/resource-name: test, crossplane.io/composition-resource-name: bucket}}}`,
+			want: []map[string]string{
+				{AnnotationKeyResourceName: "test-0", annotationKeyCompositionResourceName: "bucket-0"},
+				{AnnotationKeyResourceName: "test-1", annotationKeyCompositionResourceName: "bucket-1"},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			objects := SyntheticResources([]byte(c.template), 2, VaryFieldf("spec.id", "id-%d"))
+			got := make([]map[string]string, len(objects))
+			for i, u := range objects {
+				got[i] = u.GetAnnotations()
+				if n := u.GetName(); n != "" {
+					got[i]["name"] = n
+				}
+				if id := u.Object["spec"].(map[string]any)["id"]; id != []string{"id-0", "id-1"}[i] {
+					t.Errorf("object %d: want spec.id id-%d, got %v", i, i, id)
+				}
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("-want +got\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithSyntheticObservedResources(t *testing.T) {
+	tc := generateTc(nil)
+	WithSyntheticObservedResources([]byte(`{apiVersion: example.org/v1, kind: Bucket, metadata: {annotations: {crossplane.io/composition-resource-name: bucket}}}`), 3)(tc)
+	for _, name := range []string{"bucket-0", "bucket-1", "bucket-2"} {
+		r, exists := tc.req.GetObserved().GetResources()[name]
+		if !exists {
+			t.Fatalf("observed resource %q is missing", name)
+		}
+		if got := GetTestResourceName(convertResourceToUnstructured(r)); got != name {
+			t.Errorf("want composition resource name %q, got %q", name, got)
+		}
+	}
+}